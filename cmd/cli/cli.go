@@ -1,6 +1,9 @@
 package cli
 
 import (
+	"time"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
 	"github.com/spf13/cobra"
 )
 
@@ -14,6 +17,20 @@ var branch string
 var publishReview bool
 var markAsPublished bool
 var requestChanges bool
+var secondaryComments bool
+var publishHotspots bool
+var publishCoverage bool
+var coverageMaxComments int
+var publishDuplications bool
+var summaryMetrics []string
+var sonarTimeout time.Duration
+var sonarRetries int
+var publishTag string
+var publishTransition string
+var publishComment bool
+var caBundles []string
+var clientCert string
+var clientKey string
 
 func init() {
 	CliCmd.PersistentFlags().StringVar(&project, "project", "my-project", "Sonarqube project name")
@@ -21,6 +38,20 @@ func init() {
 	CliCmd.PersistentFlags().BoolVar(&publishReview, "publish", false, "Publish review in the SCM")
 	CliCmd.PersistentFlags().BoolVar(&markAsPublished, "mark", false, "Mark the issue as published to avoid sending it again")
 	CliCmd.PersistentFlags().BoolVar(&requestChanges, "request-changes", true, "When issue is found, mark PR as changes requested")
+	CliCmd.PersistentFlags().StringVar(&publishTag, "publish-tag", sonarqube.TAG_PUBLISHED, "Tag added to the published issues so they aren't published again, empty to not tag them")
+	CliCmd.PersistentFlags().StringVar(&publishTransition, "publish-transition", sonarqube.TRANSITION_SET_IN_REVIEW, "Transition applied to the published issues, empty to keep their status")
+	CliCmd.PersistentFlags().BoolVar(&publishComment, "publish-comment", false, "Comment the link to the PR discussion in the published issues")
+	CliCmd.PersistentFlags().BoolVar(&secondaryComments, "secondary-comments", false, "Also comment on the secondary locations of an issue that are part of the PR diff")
+	CliCmd.PersistentFlags().BoolVar(&publishHotspots, "hotspots", true, "Publish the security hotspots to review in the PR")
+	CliCmd.PersistentFlags().BoolVar(&publishCoverage, "coverage", false, "Comment on the new lines that are not covered by tests")
+	CliCmd.PersistentFlags().IntVar(&coverageMaxComments, "coverage-max-comments", 10, "Maximum coverage comments in a PR")
+	CliCmd.PersistentFlags().BoolVar(&publishDuplications, "duplications", false, "Comment on the duplicated blocks added by the PR")
+	CliCmd.PersistentFlags().StringSliceVar(&summaryMetrics, "measures", sonarqube.DefaultPullRequestMetrics, "PR metrics summarized in a PR comment updated on each analysis, empty to disable")
+	CliCmd.PersistentFlags().DurationVar(&sonarTimeout, "sonar-timeout", 10*time.Second, "Timeout of each Sonarqube request")
+	CliCmd.PersistentFlags().IntVar(&sonarRetries, "sonar-retries", 3, "Attempts of each idempotent Sonarqube request that fails temporarily, 1 to disable the retries")
+	CliCmd.PersistentFlags().StringSliceVar(&caBundles, "ca-bundle", nil, "PEM files with extra certificate authorities to trust")
+	CliCmd.PersistentFlags().StringVar(&clientCert, "client-cert", "", "PEM file with the client certificate for mutual TLS")
+	CliCmd.PersistentFlags().StringVar(&clientKey, "client-key", "", "PEM file with the client certificate key for mutual TLS")
 
 	CliCmd.AddCommand(RunCmd)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
 	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/transport"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
@@ -29,8 +30,9 @@ func Run(cmd *cobra.Command, args []string) {
 
 		return
 	}
+	sonarOrganization := os.Getenv("SONAR_ORGANIZATION")
 	sonarRootURL := os.Getenv("SONAR_ROOT_URL")
-	if sonarRootURL == "" {
+	if sonarRootURL == "" && sonarOrganization == "" {
 		logrus.Panicln("SONAR_ROOT_URL environment variable is missing")
 
 		return
@@ -46,19 +48,108 @@ func Run(cmd *cobra.Command, args []string) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
+	// HTTP transport shared by Sonarqube and the SCM
+	httpTransport, err := transport.New(transport.Config{CABundles: caBundles, ClientCert: clientCert, ClientKey: clientKey})
+	if err != nil {
+		logrus.WithError(err).Panicln("Failed to configure the HTTP transport")
+
+		return
+	}
+
 	// Sonarqube
-	sonar := sonarqube2.New(sonarRootURL, apiKey)
+	sonarOpts := []sonarqube2.Option{sonarqube2.WithTimeout(sonarTimeout), sonarqube2.WithTransport(httpTransport), sonarqube2.WithRetry(sonarRetries, 500*time.Millisecond, 10*time.Second)}
+	if sonarOrganization != "" {
+		sonarOpts = append(sonarOpts, sonarqube2.WithSonarCloud(sonarOrganization))
+	}
+	sonar := sonarqube2.New(sonarRootURL, apiKey, sonarOpts...)
 
 	// Find PR
-	pr, err := sonar.FindPRForBranch(project, branch)
+	pr, err := sonar.FindPRForBranchContext(ctx, project, branch)
 	if err != nil {
 		logrus.WithError(err).Panicln("Failed to find PR for the given branch:", branch)
 
 		return
 	}
 
+	// Setup GitHub SCM
+	var gh scm2.SCM
+	if publishReview {
+		// Check if token is set
+		if ghToken == "" {
+			logrus.Panicln("GH_TOKEN environment variable is missing")
+
+			return
+		}
+
+		gh = scm2.NewGithub(
+			ctx, sonar, ghToken,
+			scm2.WithTransport(httpTransport),
+			scm2.WithSecondaryLocationComments(secondaryComments),
+			scm2.WithSummaryMeasures(summaryMetrics),
+		)
+	}
+
+	// Check if should process the hotspots
+	if publishHotspots {
+		hotspots, err := sonar.ListHotspotsForPRContext(ctx, project, pr.Key)
+		if err != nil {
+			logrus.WithError(err).Panicln("Failed to list hotspots for the given PR:", pr.Key)
+
+			return
+		}
+
+		// Print hotspots
+		hotspots = hotspots.FilterByStatus(sonarqube2.HOTSPOT_STATUS_TO_REVIEW)
+		printHotspots(sonar, pr, hotspots.Hotspots)
+
+		if publishReview {
+			err = gh.PublishHotspotsReviewFor(ctx, hotspots.Hotspots, pr)
+			if err != nil {
+				logrus.WithError(err).Panicln("Failed to publish hotspots review")
+
+				return
+			}
+
+			logrus.Infoln("Hotspots review published!")
+		}
+	}
+
+	// Check if should publish the uncovered lines
+	if publishCoverage && publishReview {
+		err = gh.PublishCoverageReviewFor(ctx, project, pr, coverageMaxComments)
+		if err != nil {
+			logrus.WithError(err).Panicln("Failed to publish coverage review")
+
+			return
+		}
+
+		logrus.Infoln("Coverage review published!")
+	}
+
+	// Check if should publish the duplicated blocks
+	if publishDuplications && publishReview {
+		err = gh.PublishDuplicationsReviewFor(ctx, project, pr)
+		if err != nil {
+			logrus.WithError(err).Panicln("Failed to publish duplications review")
+
+			return
+		}
+
+		logrus.Infoln("Duplications review published!")
+	}
+
+	// Summarize the PR measures, the issues are still worth publishing without them
+	if publishReview {
+		err = gh.PublishSummaryFor(ctx, project, pr)
+		if err != nil {
+			logrus.WithError(err).Warnln("Failed to publish the measures summary")
+		} else {
+			logrus.Infoln("Measures summary published!")
+		}
+	}
+
 	// List issues
-	issues, err := sonar.ListIssuesForPR(project, pr.Key)
+	issues, err := sonar.ListIssuesForPRContext(ctx, project, pr.Key)
 	if err != nil {
 		logrus.WithError(err).Panicln("Failed to list issues for the given PR:", pr.Key)
 
@@ -66,7 +157,8 @@ func Run(cmd *cobra.Command, args []string) {
 	}
 
 	// Filter issues
-	issues = issues.FilterByStatus("OPEN").FilterOutByTag(sonarqube2.TAG_PUBLISHED)
+	marker := sonarqube2.PublishMarker{Tag: publishTag, Transition: publishTransition, Comment: publishComment}
+	issues = marker.Unpublished(issues.FilterByStatus("OPEN"))
 	if len(issues.Issues) == 0 {
 		logrus.Infoln("No issues found!")
 
@@ -77,19 +169,10 @@ func Run(cmd *cobra.Command, args []string) {
 	printIssues(sonar, issues.Issues)
 
 	// Check if should publish the review
+	var review *scm2.Review
 	if publishReview {
-		// Check if token is set
-		if ghToken == "" {
-			logrus.Panicln("GH_TOKEN environment variable is missing")
-
-			return
-		}
-
-		// Setup GitHub SCM
-		var gh scm2.SCM = scm2.NewGithub(ctx, sonar, ghToken)
-
 		// Publish review
-		err = gh.PublishIssuesReviewFor(ctx, issues.Issues, pr, requestChanges)
+		review, err = gh.PublishIssuesReviewFor(ctx, issues.Issues, pr, requestChanges)
 		if err != nil {
 			logrus.WithError(err).Panicln("Failed to publish issues review")
 
@@ -101,7 +184,10 @@ func Run(cmd *cobra.Command, args []string) {
 
 	// Check if should update the issues
 	if markAsPublished {
-		bulkActionRes, err := sonar.TagIssues(issues.Issues, sonarqube2.TAG_PUBLISHED)
+		bulkActionRes, err := sonar.MarkIssuesContext(ctx, issues.Issues, marker)
+		if bulkActionRes != nil && len(bulkActionRes.FailedIssues) > 0 {
+			logrus.WithField("issues", bulkActionRes.FailedIssues).Warnln("Issues not marked as published")
+		}
 		if err != nil {
 			logrus.WithError(err).Panicln("Failed to mark issues as published")
 
@@ -114,6 +200,20 @@ func Run(cmd *cobra.Command, args []string) {
 		logrus.Infoln(bulkActionRes.Ignored, "issues ignored")
 		logrus.Infoln(bulkActionRes.Failures, "issues failed")
 		logrus.Infoln("--------------------------")
+
+		// Link the issues to their PR discussion
+		if marker.Comment && review != nil && review.URL != "" {
+			commentRes, err := sonar.CommentIssuesContext(ctx, review.Published(issues.Issues), func(issue sonarqube2.Issue) string {
+				return marker.IssueComment(review.URL, review.IssueComments[issue.Key])
+			})
+			if err != nil {
+				logrus.WithError(err).WithField("issues", commentRes.FailedIssues).Errorln("Failed to comment the PR discussion link in the issues")
+
+				return
+			}
+
+			logrus.Infoln(commentRes.Success, "issues commented")
+		}
 	}
 
 }
@@ -123,3 +223,9 @@ func printIssues(sonar *sonarqube2.Sonarqube, issues []sonarqube2.Issue) {
 		logrus.Infof(fmt.Sprintf("[%s] %s: %s L%d:\n\t- %s\n", issue.Status, issue.Type, issue.FilePath(), issue.Line, issue.MarkdownMessage(sonar.Root)))
 	}
 }
+
+func printHotspots(sonar *sonarqube2.Sonarqube, pr *sonarqube2.PullRequest, hotspots []sonarqube2.Hotspot) {
+	for _, hotspot := range hotspots {
+		logrus.Infof(fmt.Sprintf("[%s] HOTSPOT: %s L%d:\n\t- %s\n", hotspot.Status, hotspot.FilePath(), hotspot.Line, hotspot.MarkdownMessage(sonar.Root, pr.Key)))
+	}
+}
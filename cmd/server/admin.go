@@ -0,0 +1,196 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ADMIN_PREFIX = "/admin/"
+
+	// adminJobsLimit is how many jobs are listed when the limit isn't given
+	adminJobsLimit = 50
+)
+
+// AdminError is the body of the failed admin requests
+type AdminError struct {
+	Error string `json:"error"`
+}
+
+// PublishRequest asks to publish the issues of a PR on demand
+type PublishRequest struct {
+	Project     string `json:"project"`
+	PullRequest string `json:"pullRequest"`
+}
+
+// AdminHandler serves the admin API to inspect and manage the jobs, every request needs the
+// admin token as a bearer token:
+//
+//	GET  /admin/jobs?status=dead&limit=50  lists the recent jobs without their payload and result
+//	GET  /admin/jobs/{id}                  returns the job with the issues published or skipped
+//	POST /admin/jobs/{id}/retry            runs the job again
+//	POST /admin/jobs/{id}/cancel           stops the job
+//	POST /admin/publish                    publishes the issues of {"project", "pullRequest"}
+func AdminHandler(token string, jobs *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !validAdminToken(req, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sqpr"`)
+			writeJSON(w, http.StatusUnauthorized, AdminError{Error: "invalid admin token"})
+
+			return
+		}
+
+		path := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, ADMIN_PREFIX), "/"), "/")
+		switch {
+		case len(path) == 1 && path[0] == "jobs" && req.Method == http.MethodGet:
+			listJobs(w, req, jobs)
+		case len(path) == 2 && path[0] == "jobs" && req.Method == http.MethodGet:
+			writeJob(w, http.StatusOK, jobs, path[1])
+		case len(path) == 3 && path[0] == "jobs" && path[2] == "retry" && req.Method == http.MethodPost:
+			job, err := jobs.Retry(path[1])
+			if err != nil {
+				adminFailed(w, err)
+
+				return
+			}
+
+			logrus.Infoln("Retrying", job.Kind, "job", path[1], "as", job.ID)
+			writeJob(w, http.StatusAccepted, jobs, job.ID)
+		case len(path) == 3 && path[0] == "jobs" && path[2] == "cancel" && req.Method == http.MethodPost:
+			if err := jobs.Cancel(path[1]); err != nil {
+				adminFailed(w, err)
+
+				return
+			}
+
+			logrus.Infoln("Cancelled job", path[1])
+			w.WriteHeader(http.StatusNoContent)
+		case len(path) == 1 && path[0] == "publish" && req.Method == http.MethodPost:
+			publishOnDemand(w, req, jobs)
+		default:
+			writeJSON(w, http.StatusNotFound, AdminError{Error: "not found"})
+		}
+	}
+}
+
+// listJobs answers the recent jobs, newest first, optionally only the ones with the given status
+func listJobs(w http.ResponseWriter, req *http.Request, jobs *queue.Queue) {
+	limit := adminJobsLimit
+	if value := req.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			writeJSON(w, http.StatusBadRequest, AdminError{Error: "limit must be a positive number"})
+
+			return
+		}
+		limit = parsed
+	}
+	status := req.URL.Query().Get("status")
+
+	all, err := jobs.Jobs()
+	if err != nil {
+		adminFailed(w, err)
+
+		return
+	}
+
+	listed := make([]*queue.Job, 0, limit)
+	for _, job := range all {
+		if len(listed) == limit {
+			break
+		}
+		if status != "" && job.Status != status {
+			continue
+		}
+
+		// The details are in the job endpoint
+		job.Payload = nil
+		job.Result = nil
+		listed = append(listed, job)
+	}
+
+	writeJSON(w, http.StatusOK, listed)
+}
+
+// publishOnDemand queues the publishing of the given PR, merged into the one already waiting
+func publishOnDemand(w http.ResponseWriter, req *http.Request, jobs *queue.Queue) {
+	var publish PublishRequest
+	if err := json.NewDecoder(req.Body).Decode(&publish); err != nil {
+		writeJSON(w, http.StatusBadRequest, AdminError{Error: "invalid JSON body"})
+
+		return
+	}
+	if publish.Project == "" || publish.PullRequest == "" {
+		writeJSON(w, http.StatusBadRequest, AdminError{Error: "project and pullRequest are required"})
+
+		return
+	}
+
+	job := NewBranchPublishJob(publish.Project, publish.PullRequest, sonarqube2.BRANCH_TYPE_PULL_REQUEST)
+	queued := *job
+	if err := jobs.Enqueue(job); err != nil {
+		adminFailed(w, err)
+
+		return
+	}
+	queued.ID = job.ID
+
+	logrus.Infoln("Publish requested for", publish.Project, "->", publish.PullRequest)
+
+	// Finished already and not kept in the history
+	stored, err := jobs.Job(queued.ID)
+	if err != nil {
+		stored = &queued
+	}
+
+	writeJSON(w, http.StatusAccepted, stored)
+}
+
+// writeJob answers the stored job with the given ID, the queued one may be changing in a worker
+func writeJob(w http.ResponseWriter, status int, jobs *queue.Queue, id string) {
+	job, err := jobs.Job(id)
+	if err != nil {
+		adminFailed(w, err)
+
+		return
+	}
+
+	writeJSON(w, status, job)
+}
+
+// adminFailed answers the admin request with the status matching the error
+func adminFailed(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, queue.ErrJobNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, queue.ErrJobActive), errors.Is(err, queue.ErrJobFinished):
+		status = http.StatusConflict
+	case errors.Is(err, queue.ErrQueueFull), errors.Is(err, queue.ErrQueueClosed):
+		w.Header().Set("Retry-After", strconv.Itoa(int(queueFullRetryAfter.Seconds())))
+		status = http.StatusServiceUnavailable
+	default:
+		logrus.WithError(err).Errorln("Admin request failed")
+	}
+
+	writeJSON(w, status, AdminError{Error: err.Error()})
+}
+
+// validAdminToken checks the bearer token of the request in constant time
+func validAdminToken(req *http.Request, token string) bool {
+	authorization := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	reqToken := strings.TrimPrefix(authorization, "Bearer ")
+
+	return token != "" && subtle.ConstantTimeCompare([]byte(reqToken), []byte(token)) == 1
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRequest(handler http.Handler, method string, path string, body string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	return res
+}
+
+func TestAdminHandlerRequiresToken(t *testing.T) {
+	handler := AdminHandler("mytoken", queue.New(queue.NewMemoryStore(), nil))
+
+	res := adminRequest(handler, http.MethodGet, "/admin/jobs", "", "")
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	res = adminRequest(handler, http.MethodGet, "/admin/jobs", "", "wrongtoken")
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	// The bearer scheme is required
+	req := httptest.NewRequest(http.MethodGet, "/admin/jobs", nil)
+	req.Header.Set("Authorization", "mytoken")
+	raw := httptest.NewRecorder()
+	handler.ServeHTTP(raw, req)
+	assert.Equal(t, http.StatusUnauthorized, raw.Code)
+
+	res = adminRequest(handler, http.MethodGet, "/admin/jobs", "", "mytoken")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `[]`, res.Body.String())
+}
+
+func TestAdminHandlerManagesJobs(t *testing.T) {
+	jobs := queue.New(queue.NewMemoryStore(), func(ctx context.Context, job *queue.Job) error {
+		report := NewPublishReport()
+		report.Skipped = append(report.Skipped, SkippedIssue{Key: "AX2GHjk1-Wk2ioy15Nrv", Reason: SKIP_OUTSIDE_DIFF})
+		if err := job.SetResult(report); err != nil {
+			return err
+		}
+
+		return errors.New("invalid token")
+	}, queue.WithRetryIf(func(err error) bool { return false }))
+	assert.NoError(t, jobs.Start(1))
+	defer jobs.Shutdown(context.Background())
+	handler := AdminHandler("mytoken", jobs)
+
+	job := NewBranchPublishJob("myproject", "5", sonarqube2.BRANCH_TYPE_PULL_REQUEST)
+	assert.NoError(t, jobs.Enqueue(job))
+	assert.Eventually(t, func() bool {
+		dead, _ := jobs.DeadLetters()
+
+		return len(dead) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// List without the details
+	res := adminRequest(handler, http.MethodGet, "/admin/jobs?status=dead", "", "mytoken")
+	assert.Equal(t, http.StatusOK, res.Code)
+	var listed []*queue.Job
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &listed))
+	assert.Len(t, listed, 1)
+	assert.Equal(t, job.ID, listed[0].ID)
+	assert.Equal(t, "invalid token", listed[0].LastError)
+	assert.Empty(t, listed[0].Result)
+
+	res = adminRequest(handler, http.MethodGet, "/admin/jobs?status=done", "", "mytoken")
+	assert.JSONEq(t, `[]`, res.Body.String())
+
+	res = adminRequest(handler, http.MethodGet, "/admin/jobs?limit=none", "", "mytoken")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// Details with the skipped issues
+	res = adminRequest(handler, http.MethodGet, "/admin/jobs/"+job.ID, "", "mytoken")
+	assert.Equal(t, http.StatusOK, res.Code)
+	var found queue.Job
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &found))
+	assert.Equal(t, queue.STATUS_DEAD, found.Status)
+	assert.JSONEq(t, `{"published":[],"skipped":[{"key":"AX2GHjk1-Wk2ioy15Nrv","reason":"outside the PR diff"}]}`, string(found.Result))
+
+	res = adminRequest(handler, http.MethodGet, "/admin/jobs/missing", "", "mytoken")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+
+	// Dead letters can't be cancelled but can be retried
+	res = adminRequest(handler, http.MethodPost, "/admin/jobs/"+job.ID+"/cancel", "", "mytoken")
+	assert.Equal(t, http.StatusConflict, res.Code)
+
+	res = adminRequest(handler, http.MethodPost, "/admin/jobs/"+job.ID+"/retry", "", "mytoken")
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	assert.Eventually(t, func() bool {
+		found, err := jobs.Job(job.ID)
+
+		return err == nil && found.Status == queue.STATUS_DEAD && jobs.Failed() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	res = adminRequest(handler, http.MethodDelete, "/admin/jobs/"+job.ID, "", "mytoken")
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestAdminHandlerPublishesOnDemand(t *testing.T) {
+	// Not started so the jobs keep waiting
+	jobs := queue.New(queue.NewMemoryStore(), nil)
+	handler := AdminHandler("mytoken", jobs)
+
+	res := adminRequest(handler, http.MethodPost, "/admin/publish", `{"project":"myproject","pullRequest":"5"}`, "mytoken")
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	var job queue.Job
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &job))
+	assert.Equal(t, JOB_PUBLISH, job.Kind)
+	assert.Equal(t, "myproject/5", job.Key)
+	assert.Equal(t, "5", job.Branch)
+	assert.Equal(t, sonarqube2.BRANCH_TYPE_PULL_REQUEST, job.BranchType)
+	assert.Equal(t, queue.STATUS_PENDING, job.Status)
+
+	// Merged into the waiting one
+	res = adminRequest(handler, http.MethodPost, "/admin/publish", `{"project":"myproject","pullRequest":"5"}`, "mytoken")
+	assert.Equal(t, http.StatusAccepted, res.Code)
+	var merged queue.Job
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &merged))
+	assert.Equal(t, job.ID, merged.ID)
+	assert.Equal(t, 1, jobs.Depth())
+
+	res = adminRequest(handler, http.MethodPost, "/admin/publish", `{"project":"myproject"}`, "mytoken")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	res = adminRequest(handler, http.MethodPost, "/admin/publish", `not json`, "mytoken")
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const commandsHelp = "Available commands:\n\n" +
+	"- `/sqpr republish` publishes all the open issues again\n" +
+	"- `/sqpr ignore <rule>` ignores the issues of the given rule in this PR\n" +
+	"- `/sqpr summary` comments the PR measures\n" +
+	"- `/sqpr falsepositive` as a reply to an issue comment marks it as false positive"
+
+// RunCommand executes the given slash command when the user is allowed to, reacting to the comment with the result
+func RunCommand(ctx context.Context, sonar *sonarqube2.Sonarqube, gh *scm2.Github, event *scm2.CommandEvent) error {
+	canWrite, err := gh.CanWrite(ctx, event.Owner, event.Repo, event.User)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to check the permission of %s", event.User))
+	}
+	if !canWrite {
+		logrus.Infoln("Ignoring command", event.Command.Name, "by", event.User, "without write permission")
+
+		return gh.ReactToComment(ctx, event.Owner, event.Repo, event.CommentID, scm2.REACTION_DENIED)
+	}
+
+	project := projectFor(event.Owner, event.Repo)
+	prKey := strconv.Itoa(event.PRNumber)
+
+	switch event.Command.Name {
+	case scm2.COMMAND_REPUBLISH:
+		_, err = PublishIssues(ctx, sonar, gh, project, prKey, sonarqube2.BRANCH_TYPE_PULL_REQUEST, true)
+	case scm2.COMMAND_IGNORE:
+		if len(event.Command.Args) != 1 {
+			return replyConfused(ctx, gh, event, "Usage: `/sqpr ignore <rule>`, like `/sqpr ignore go:S1234`")
+		}
+
+		err = ignoreRule(ctx, sonar, gh, event, project, prKey, event.Command.Args[0])
+	case scm2.COMMAND_SUMMARY:
+		err = commentSummary(ctx, sonar, gh, event, project, prKey)
+	case scm2.COMMAND_FALSEPOSITIVE:
+		return replyConfused(ctx, gh, event, "`/sqpr falsepositive` must be a reply in the thread of the issue comment to mark")
+	default:
+		return replyConfused(ctx, gh, event, fmt.Sprintf("Unknown command `%s`. %s", event.Command.Name, commandsHelp))
+	}
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to run command %s for %s", event.Command.Name, event.User))
+	}
+
+	logrus.Infoln("Command", event.Command.Name, "by", event.User, "done for", project, prKey)
+	react(ctx, gh, event, scm2.REACTION_DONE)
+
+	return nil
+}
+
+// replyConfused answers a command that can't run with the given explanation
+func replyConfused(ctx context.Context, gh *scm2.Github, event *scm2.CommandEvent, body string) error {
+	err := gh.Reply(ctx, event.Owner, event.Repo, event.PRNumber, body)
+	if err != nil {
+		return err
+	}
+
+	react(ctx, gh, event, scm2.REACTION_CONFUSED)
+
+	return nil
+}
+
+// react adds the result reaction to the command comment, a failure is only logged as retrying
+// the job would run the command and post its replies again
+func react(ctx context.Context, gh *scm2.Github, event *scm2.CommandEvent, reaction string) {
+	if err := gh.ReactToComment(ctx, event.Owner, event.Repo, event.CommentID, reaction); err != nil {
+		logrus.WithError(err).Warnln("Failed to react to command", event.Command.Name, "by", event.User)
+	}
+}
+
+// ignoreRule applies the ignore transition to the open issues of the given rule in the PR
+func ignoreRule(ctx context.Context, sonar *sonarqube2.Sonarqube, gh *scm2.Github, event *scm2.CommandEvent, project string, prKey string, rule string) error {
+	issues, err := sonar.ListIssuesForPRContext(ctx, project, prKey)
+	if err != nil {
+		return err
+	}
+
+	ignored := 0
+	for _, issue := range issues.FilterByStatus("OPEN").Issues {
+		if issue.Rule != rule {
+			continue
+		}
+
+		err = sonar.TransitionIssueContext(ctx, issue.Key, ignoreTransition)
+		if err != nil {
+			return err
+		}
+
+		err = sonar.CommentIssueContext(ctx, issue.Key, fmt.Sprintf("Ignored by %s in the PR", event.User))
+		if err != nil {
+			return err
+		}
+		ignored++
+	}
+
+	return gh.Reply(ctx, event.Owner, event.Repo, event.PRNumber, fmt.Sprintf("Ignored %d issues of the rule `%s`", ignored, rule))
+}
+
+// commentSummary comments the PR measures in the PR conversation
+func commentSummary(ctx context.Context, sonar *sonarqube2.Sonarqube, gh *scm2.Github, event *scm2.CommandEvent, project string, prKey string) error {
+	pr, err := sonar.FindPRForKeyContext(ctx, project, prKey)
+	if err != nil {
+		return err
+	}
+
+	// The command asks for the summary even when it's disabled in the reviews
+	metrics := summaryMetrics
+	if len(metrics) == 0 {
+		metrics = sonarqube2.DefaultPullRequestMetrics
+	}
+
+	summary, err := sonar.PullRequestSummaryContext(ctx, project, pr, metrics)
+	if err != nil {
+		return err
+	}
+	if summary == "" {
+		summary = "No measures available for this PR yet"
+	}
+
+	return gh.Reply(ctx, event.Owner, event.Repo, event.PRNumber, summary)
+}
+
+// projectFor returns the Sonarqube project of the given GitHub repository
+func projectFor(owner string, repo string) string {
+	if project, ok := githubProjects[fmt.Sprintf("%s/%s", owner, repo)]; ok {
+		return project
+	}
+
+	return fmt.Sprintf("%s_%s", owner, repo)
+}
+
+// isFalsePositiveCommand checks if the reply is the false positive command
+func isFalsePositiveCommand(reply string) bool {
+	command, ok := scm2.ParseCommand(reply)
+
+	return ok && command.Name == scm2.COMMAND_FALSEPOSITIVE
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v41/github"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+// commandSonar answers the PR, its issues and measures, keeping the applied transitions and comments
+type commandSonar struct {
+	mu          sync.Mutex
+	transitions []string
+	comments    []string
+}
+
+func (c *commandSonar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api/project_pull_requests/list":
+		w.Write([]byte(`{"pullRequests":[{"key":"3","branch":"feat/newtest"}]}`))
+	case "/api/measures/component":
+		w.Write([]byte(`{"component":{"key":"myproject","measures":[{"metric":"new_coverage","period":{"index":1,"value":"82.5"}}]}}`))
+	case "/api/issues/search":
+		w.Write([]byte(`{"total":3,"p":1,"ps":500,"issues":[` +
+			`{"key":"issue-1","rule":"go:S1234","status":"OPEN"},` +
+			`{"key":"issue-2","rule":"go:S1234","status":"RESOLVED"},` +
+			`{"key":"issue-3","rule":"go:S4321","status":"OPEN"}]}`))
+	case "/api/issues/do_transition":
+		c.transitions = append(c.transitions, r.FormValue("issue")+" "+r.FormValue("transition"))
+	case "/api/issues/add_comment":
+		c.comments = append(c.comments, r.FormValue("issue")+" "+r.FormValue("text"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	defer func(transition string, metrics []string) { ignoreTransition, summaryMetrics = transition, metrics }(ignoreTransition, summaryMetrics)
+	ignoreTransition = "wontfix"
+	summaryMetrics = []string{"new_coverage"}
+
+	tests := []struct {
+		name         string
+		permission   string
+		command      string
+		args         []string
+		reactionCode int
+		transitions  []string
+		comments     []string
+		replies      []string
+		reactions    []string
+	}{
+		{
+			name:       "denied",
+			permission: "read",
+			command:    scm2.COMMAND_IGNORE,
+			args:       []string{"go:S1234"},
+			reactions:  []string{scm2.REACTION_DENIED},
+		},
+		{
+			name:        "ignore",
+			permission:  "write",
+			command:     scm2.COMMAND_IGNORE,
+			args:        []string{"go:S1234"},
+			transitions: []string{"issue-1 wontfix"},
+			comments:    []string{"issue-1 Ignored by herlon214 in the PR"},
+			replies:     []string{"Ignored 1 issues of the rule `go:S1234`"},
+			reactions:   []string{scm2.REACTION_DONE},
+		},
+		{
+			name:       "ignore without rule",
+			permission: "write",
+			command:    scm2.COMMAND_IGNORE,
+			replies:    []string{"Usage: `/sqpr ignore <rule>`, like `/sqpr ignore go:S1234`"},
+			reactions:  []string{scm2.REACTION_CONFUSED},
+		},
+		{
+			name:       "summary",
+			permission: "write",
+			command:    scm2.COMMAND_SUMMARY,
+			replies:    []string{"| Metric | This PR |\n|---|---|\n| Coverage | 82.5% |"},
+			reactions:  []string{scm2.REACTION_DONE},
+		},
+		{
+			name:       "false positive in the conversation",
+			permission: "write",
+			command:    scm2.COMMAND_FALSEPOSITIVE,
+			replies:    []string{"`/sqpr falsepositive` must be a reply in the thread of the issue comment to mark"},
+			reactions:  []string{scm2.REACTION_CONFUSED},
+		},
+		{
+			name:       "unknown",
+			permission: "write",
+			command:    "fix",
+			replies:    []string{"Unknown command `fix`. " + commandsHelp},
+			reactions:  []string{scm2.REACTION_CONFUSED},
+		},
+		{
+			name:         "reaction failure",
+			permission:   "write",
+			command:      scm2.COMMAND_SUMMARY,
+			reactionCode: http.StatusBadGateway,
+			replies:      []string{"| Metric | This PR |\n|---|---|\n| Coverage | 82.5% |"},
+			reactions:    []string{scm2.REACTION_DONE},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sonarHandler := &commandSonar{}
+			svr := httptest.NewServer(sonarHandler)
+			defer svr.Close()
+
+			version, err := sonarqube2.ParseVersion("9.9.0.65466")
+			assert.Nil(t, err)
+			sonar := sonarqube2.New(svr.URL, "my-key", sonarqube2.WithServerVersion(version))
+
+			replies := []string{}
+			reactions := []string{}
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposCollaboratorsPermissionByOwnerByRepoByUsername,
+					github.RepositoryPermissionLevel{Permission: github.String(test.permission)},
+				),
+				mock.WithRequestMatchHandler(
+					mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						var comment github.IssueComment
+						assert.Nil(t, json.NewDecoder(r.Body).Decode(&comment))
+						replies = append(replies, comment.GetBody())
+						w.Write(mock.MustMarshal(comment))
+					}),
+				),
+				mock.WithRequestMatchHandler(
+					mock.PostReposIssuesCommentsReactionsByOwnerByRepoByCommentId,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						var reaction github.Reaction
+						assert.Nil(t, json.NewDecoder(r.Body).Decode(&reaction))
+						reactions = append(reactions, reaction.GetContent())
+						if test.reactionCode != 0 {
+							w.WriteHeader(test.reactionCode)
+
+							return
+						}
+						w.Write(mock.MustMarshal(reaction))
+					}),
+				),
+			)
+			gh := scm2.NewGithub(context.Background(), sonar, "mytoken", scm2.WithTransport(mockedHTTPClient.Transport))
+
+			err = RunCommand(context.Background(), sonar, gh, &scm2.CommandEvent{
+				Owner:     "herlon214",
+				Repo:      "sonarqube-pr-issues",
+				PRNumber:  3,
+				User:      "herlon214",
+				CommentID: 45,
+				Command:   &scm2.Command{Name: test.command, Args: test.args},
+			})
+			assert.Nil(t, err)
+
+			assert.Equal(t, test.transitions, sonarHandler.transitions)
+			assert.Equal(t, test.comments, sonarHandler.comments)
+			if test.replies == nil {
+				test.replies = []string{}
+			}
+			assert.Equal(t, test.replies, replies)
+			assert.Equal(t, test.reactions, reactions)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DEFAULT_DASHBOARD_DAYS is the period of the published issues when the days aren't given
+	DEFAULT_DASHBOARD_DAYS = 7
+	// dashboardJobs is how many of the recent jobs are shown
+	dashboardJobs = 50
+)
+
+//go:embed templates/dashboard.html
+var dashboardTemplate string
+
+var dashboardPage = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"ago": func(at time.Time) string {
+		if at.IsZero() {
+			return "-"
+		}
+
+		return time.Since(at).Truncate(time.Second).String() + " ago"
+	},
+}).Parse(dashboardTemplate))
+
+// Dashboard is what the dashboard page shows
+type Dashboard struct {
+	GeneratedAt time.Time
+	Days        int
+	Deliveries  []Delivery
+	Jobs        []*queue.Job
+	Failures    []*queue.Job
+	Projects    []*ProjectActivity
+	Depth       int
+	Capacity    int
+	Busy        int
+	Workers     int
+	Processed   uint64
+	Failed      uint64
+}
+
+// ProjectActivity is what was published for a project in the dashboard period
+type ProjectActivity struct {
+	Project   string
+	Reviews   int
+	Published int
+	Skipped   int
+}
+
+// DashboardHandler renders the recent webhooks, the job outcomes, the issues published by project and the
+// current failures in a page protected by basic auth with the given password, any user name is accepted
+func DashboardHandler(password string, jobs *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		_, reqPassword, _ := req.BasicAuth()
+		if password == "" || subtle.ConstantTimeCompare([]byte(reqPassword), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="sqpr"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		days := DEFAULT_DASHBOARD_DAYS
+		if value := req.URL.Query().Get("days"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "days must be a positive number", http.StatusBadRequest)
+
+				return
+			}
+			days = parsed
+		}
+
+		dashboard, err := NewDashboard(jobs, days)
+		if err != nil {
+			logrus.WithError(err).Errorln("Failed to load the dashboard")
+			http.Error(w, "Failed to load the jobs", http.StatusInternalServerError)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardPage.Execute(w, dashboard); err != nil {
+			logrus.WithError(err).Warnln("Failed to render the dashboard")
+		}
+	}
+}
+
+// NewDashboard gathers the dashboard of the given queue, with the issues published and the jobs that failed in the last days
+func NewDashboard(jobs *queue.Queue, days int) (*Dashboard, error) {
+	all, err := jobs.Jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	dashboard := &Dashboard{
+		GeneratedAt: now,
+		Days:        days,
+		Deliveries:  deliveries.Recent(),
+		Depth:       jobs.Depth(),
+		Capacity:    jobs.Capacity(),
+		Busy:        jobs.Busy(),
+		Workers:     jobs.Workers(),
+		Processed:   jobs.Processed(),
+		Failed:      jobs.Failed(),
+	}
+
+	since := now.AddDate(0, 0, -days)
+	projects := make(map[string]*ProjectActivity)
+	for i, job := range all {
+		if i < dashboardJobs {
+			dashboard.Jobs = append(dashboard.Jobs, job)
+		}
+
+		// Dead letters of the period and the ones waiting for a retry
+		if (job.Status == queue.STATUS_DEAD && !job.FinishedAt.Before(since)) || (job.Status == queue.STATUS_SCHEDULED && job.LastError != "") {
+			dashboard.Failures = append(dashboard.Failures, job)
+		}
+
+		if job.Kind != JOB_PUBLISH || job.Status != queue.STATUS_DONE || job.FinishedAt.Before(since) || len(job.Result) == 0 {
+			continue
+		}
+
+		var report PublishReport
+		if err := json.Unmarshal(job.Result, &report); err != nil {
+			logrus.WithError(err).Warnln("Ignoring the invalid publish report of job", job.ID)
+
+			continue
+		}
+
+		activity, ok := projects[job.Project]
+		if !ok {
+			activity = &ProjectActivity{Project: job.Project}
+			projects[job.Project] = activity
+		}
+		if report.ReviewURL != "" {
+			activity.Reviews++
+		}
+		activity.Published += len(report.Published)
+		activity.Skipped += len(report.Skipped)
+	}
+
+	for _, activity := range projects {
+		dashboard.Projects = append(dashboard.Projects, activity)
+	}
+	sort.Slice(dashboard.Projects, func(i, j int) bool {
+		if dashboard.Projects[i].Published != dashboard.Projects[j].Published {
+			return dashboard.Projects[i].Published > dashboard.Projects[j].Published
+		}
+
+		return dashboard.Projects[i].Project < dashboard.Projects[j].Project
+	})
+
+	return dashboard, nil
+}
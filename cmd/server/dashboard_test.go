@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboardHandler(t *testing.T) {
+	jobs := queue.New(queue.NewMemoryStore(), func(ctx context.Context, job *queue.Job) error {
+		if job.Project == "broken" {
+			return errors.New("invalid token")
+		}
+
+		report := NewPublishReport()
+		report.ReviewURL = "https://github.com/owner/repo/pull/5#pullrequestreview-1"
+		report.Published = []string{"AX2GHjk1-Wk2ioy15Nrt", "AX2GHjk1-Wk2ioy15Nru"}
+		report.Skipped = []SkippedIssue{{Key: "AX2GHjk1-Wk2ioy15Nrv", Reason: SKIP_OUTSIDE_DIFF}}
+
+		return job.SetResult(report)
+	}, queue.WithHistory(time.Hour), queue.WithRetryIf(func(err error) bool { return false }))
+	assert.NoError(t, jobs.Start(1))
+	defer jobs.Shutdown(context.Background())
+
+	assert.NoError(t, jobs.Enqueue(NewBranchPublishJob("myproject", "5", sonarqube2.BRANCH_TYPE_PULL_REQUEST)))
+	assert.NoError(t, jobs.Enqueue(NewBranchPublishJob("broken", "6", sonarqube2.BRANCH_TYPE_PULL_REQUEST)))
+	assert.Eventually(t, func() bool {
+		return jobs.Processed()+jobs.Failed() == 2
+	}, time.Second, 10*time.Millisecond)
+	deliveries.Record(Delivery{At: time.Now(), Source: WEBHOOK_SOURCE_GITHUB, Status: http.StatusUnauthorized, Reason: REJECT_BAD_SIGNATURE})
+
+	dashboard, err := NewDashboard(jobs, DEFAULT_DASHBOARD_DAYS)
+	assert.NoError(t, err)
+	assert.Len(t, dashboard.Jobs, 2)
+	assert.Len(t, dashboard.Failures, 1)
+	assert.Equal(t, "broken", dashboard.Failures[0].Project)
+	assert.Equal(t, []*ProjectActivity{{Project: "myproject", Reviews: 1, Published: 2, Skipped: 1}}, dashboard.Projects)
+
+	handler := DashboardHandler("mypassword", jobs)
+
+	res := httptest.NewRecorder()
+	handler(res, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, `Basic realm="sqpr"`, res.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest(http.MethodGet, "/dashboard?days=30", nil)
+	req.SetBasicAuth("teamlead", "mypassword")
+	res = httptest.NewRecorder()
+	handler(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "text/html; charset=utf-8", res.Header().Get("Content-Type"))
+	assert.Contains(t, res.Body.String(), "Issues published in the last 30 days")
+	assert.Contains(t, res.Body.String(), "<td>myproject</td><td>1</td><td>2</td><td>1</td>")
+	assert.Contains(t, res.Body.String(), "invalid token")
+	assert.Contains(t, res.Body.String(), "Refused: bad_signature")
+
+	req = httptest.NewRequest(http.MethodGet, "/dashboard?days=-1", nil)
+	req.SetBasicAuth("teamlead", "mypassword")
+	res = httptest.NewRecorder()
+	handler(res, req)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestDashboardOnlyShowsTheFailuresOfThePeriod(t *testing.T) {
+	store := queue.NewMemoryStore()
+
+	old := NewBranchPublishJob("myproject", "5", sonarqube2.BRANCH_TYPE_PULL_REQUEST)
+	old.Status = queue.STATUS_DEAD
+	old.FinishedAt = time.Now().AddDate(0, 0, -10)
+	recent := NewBranchPublishJob("myproject", "6", sonarqube2.BRANCH_TYPE_PULL_REQUEST)
+	recent.Status = queue.STATUS_DEAD
+	recent.FinishedAt = time.Now()
+	assert.NoError(t, store.PutDead(old))
+	assert.NoError(t, store.PutDead(recent))
+	jobs := queue.New(store, nil)
+
+	dashboard, err := NewDashboard(jobs, DEFAULT_DASHBOARD_DAYS)
+	assert.NoError(t, err)
+	assert.Len(t, dashboard.Failures, 1)
+	assert.Equal(t, recent.ID, dashboard.Failures[0].ID)
+
+	dashboard, err = NewDashboard(jobs, 30)
+	assert.NoError(t, err)
+	assert.Len(t, dashboard.Failures, 2)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DEFAULT_DELIVERIES is how many webhook deliveries are kept for the dashboard
+const DEFAULT_DELIVERIES = 100
+
+// deliveries keeps the recent webhooks received by the server
+var deliveries = NewDeliveryLog(DEFAULT_DELIVERIES)
+
+// Delivery is a webhook received by the server and how it was answered
+type Delivery struct {
+	At     time.Time
+	Source string
+	Status int
+	// Reason is why the webhook was refused, empty when it was accepted
+	Reason string
+	// Detail tells what the webhook was about, like the project and branch
+	Detail string
+}
+
+// DeliveryLog keeps the last webhook deliveries in memory, they are lost on restart
+type DeliveryLog struct {
+	mutex      sync.Mutex
+	size       int
+	deliveries []Delivery
+}
+
+// NewDeliveryLog creates a log that keeps the given number of deliveries
+func NewDeliveryLog(size int) *DeliveryLog {
+	return &DeliveryLog{size: size, deliveries: make([]Delivery, 0, size)}
+}
+
+// Record adds the delivery, dropping the oldest one when the log is full
+func (d *DeliveryLog) Record(delivery Delivery) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if len(d.deliveries) == d.size {
+		d.deliveries = d.deliveries[1:]
+	}
+	d.deliveries = append(d.deliveries, delivery)
+}
+
+// Recent returns the deliveries, newest first
+func (d *DeliveryLog) Recent() []Delivery {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	recent := make([]Delivery, len(d.deliveries))
+	for i, delivery := range d.deliveries {
+		recent[len(d.deliveries)-1-i] = delivery
+	}
+
+	return recent
+}
+
+// acceptWebhook answers the webhook with 200, keeping what it was about for the dashboard
+func acceptWebhook(w http.ResponseWriter, source string, detail string) {
+	deliveries.Record(Delivery{At: time.Now(), Source: source, Status: http.StatusOK, Detail: detail})
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,20 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryLog(t *testing.T) {
+	log := NewDeliveryLog(2)
+	log.Record(Delivery{Source: WEBHOOK_SOURCE_SONARQUBE, Status: http.StatusOK, Detail: "myproject -> 1"})
+	log.Record(Delivery{Source: WEBHOOK_SOURCE_GITHUB, Status: http.StatusUnauthorized, Reason: REJECT_BAD_SIGNATURE})
+	log.Record(Delivery{Source: WEBHOOK_SOURCE_SONARQUBE, Status: http.StatusOK, Detail: "myproject -> 3"})
+
+	recent := log.Recent()
+	assert.Len(t, recent, 2)
+	assert.Equal(t, "myproject -> 3", recent[0].Detail)
+	assert.Equal(t, REJECT_BAD_SIGNATURE, recent[1].Reason)
+}
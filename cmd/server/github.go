@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// GithubWebhookHandler receives the GitHub webhooks with the slash commands and the developers feedback to sync back into Sonarqube
+func GithubWebhookHandler(webhookSecret string, gh *scm2.Github, jobs *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		webhooksReceived.WithLabelValues(WEBHOOK_SOURCE_GITHUB).Inc()
+
+		// Check signature
+		payload, err := github.ValidatePayload(req, []byte(webhookSecret))
+		if err != nil {
+			rejectWebhook(w, WEBHOOK_SOURCE_GITHUB, REJECT_BAD_SIGNATURE, http.StatusUnauthorized)
+
+			return
+		}
+
+		eventType := github.WebHookType(req)
+
+		// Slash commands
+		command, err := gh.CommandFor(eventType, payload)
+		if err != nil {
+			logrus.WithError(err).Warnln("Failed to read the command from the", eventType, "event")
+			rejectWebhook(w, WEBHOOK_SOURCE_GITHUB, REJECT_BAD_JSON, http.StatusBadRequest)
+
+			return
+		}
+		if command != nil {
+			logrus.Infoln("Adding to the queue command", command.Command.Name, "by", command.User)
+			job, err := NewCommandJob(command)
+			if !enqueue(w, jobs, job, err) {
+				return
+			}
+
+			// Acknowledge the command was received
+			if err := gh.ReactToComment(req.Context(), command.Owner, command.Repo, command.CommentID, scm2.REACTION_SEEN); err != nil {
+				logrus.WithError(err).Warnln("Failed to acknowledge the command")
+			}
+
+			acceptWebhook(w, WEBHOOK_SOURCE_GITHUB, fmt.Sprintf("/sqpr %s by %s in %s/%s#%d", command.Command.Name, command.User, command.Owner, command.Repo, command.PRNumber))
+
+			return
+		}
+
+		feedback, err := gh.ThreadFeedbackFor(req.Context(), eventType, payload)
+		if err != nil {
+			logrus.WithError(err).Warnln("Failed to read the feedback from the", eventType, "event")
+			rejectWebhook(w, WEBHOOK_SOURCE_GITHUB, REJECT_ERROR, http.StatusBadRequest)
+
+			return
+		}
+
+		// Not about an issue comment
+		if feedback == nil {
+			acceptWebhook(w, WEBHOOK_SOURCE_GITHUB, fmt.Sprintf("%s event ignored", eventType))
+
+			return
+		}
+
+		logrus.Infoln("Adding to the queue", feedback.IssueKey, "feedback by", feedback.User)
+		job, err := queue.NewPayloadJob(JOB_FEEDBACK, feedback)
+		if !enqueue(w, jobs, job, err) {
+			return
+		}
+
+		acceptWebhook(w, WEBHOOK_SOURCE_GITHUB, fmt.Sprintf("Feedback on %s by %s", feedback.IssueKey, feedback.User))
+	}
+}
+
+// enqueue adds the given job into the queue, answering the request when it couldn't be created or queued
+func enqueue(w http.ResponseWriter, jobs *queue.Queue, job *queue.Job, err error) bool {
+	if err != nil {
+		logrus.WithError(err).Errorln("Failed to create job")
+		rejectWebhook(w, WEBHOOK_SOURCE_GITHUB, REJECT_ERROR, http.StatusInternalServerError)
+
+		return false
+	}
+
+	logrus.WithField("depth", jobs.Depth()).Debugln("Adding", job.Kind, "job to the queue")
+	if err := jobs.Enqueue(job); err != nil {
+		enqueueFailed(w, WEBHOOK_SOURCE_GITHUB, jobs, job, err)
+
+		return false
+	}
+
+	return true
+}
+
+// SyncFeedback applies the transition of the given feedback to its issue and copies the reply as an issue comment
+func SyncFeedback(ctx context.Context, sonar *sonarqube2.Sonarqube, gh *scm2.Github, feedback *scm2.ThreadFeedback) error {
+	// Only the people allowed to change the code can dismiss an issue
+	canWrite, err := gh.CanWrite(ctx, feedback.Owner, feedback.Repo, feedback.User)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to check the permission of %s", feedback.User))
+	}
+	isCommand := isFalsePositiveCommand(feedback.Reply)
+	if !canWrite {
+		logrus.Infoln("Ignoring feedback on", feedback.IssueKey, "by", feedback.User, "without write permission")
+		if isCommand {
+			return gh.ReactToReviewComment(ctx, feedback.Owner, feedback.Repo, feedback.CommentID, scm2.REACTION_DENIED)
+		}
+
+		return nil
+	}
+
+	// The marker only names the issue, it must belong to the project of the repository
+	issue, err := sonar.IssueContext(ctx, feedback.IssueKey)
+	if err != nil {
+		return err
+	}
+	project := projectFor(feedback.Owner, feedback.Repo)
+	if issue.Project != project && !strings.HasPrefix(issue.Component, project+":") {
+		logrus.Warnln("Ignoring feedback on", feedback.IssueKey, "of the project", issue.Project, "in", feedback.Owner+"/"+feedback.Repo, "mapped to", project)
+		if isCommand {
+			return gh.ReactToReviewComment(ctx, feedback.Owner, feedback.Repo, feedback.CommentID, scm2.REACTION_DENIED)
+		}
+
+		return nil
+	}
+
+	transition := syncResolveTransition
+	if isCommand {
+		transition = sonarqube2.TRANSITION_FALSE_POSITIVE
+	} else if !feedback.Resolved {
+		transition = scm2.ReplyTransition(feedback.Reply, syncReplyTransitions)
+	}
+
+	if transition != "" {
+		applied, err := transitionIssue(ctx, sonar, issue, transition)
+		if err != nil {
+			return err
+		}
+		if applied {
+			logrus.Infoln("Applied", transition, "to", feedback.IssueKey, "after feedback by", feedback.User)
+		}
+	}
+
+	if feedback.Reply != "" {
+		err = sonar.CommentIssueContext(ctx, feedback.IssueKey, fmt.Sprintf("%s replied in the PR:\n\n%s", feedback.User, feedback.Reply))
+		if err != nil {
+			return err
+		}
+	}
+
+	if isCommand {
+		return gh.ReactToReviewComment(ctx, feedback.Owner, feedback.Repo, feedback.CommentID, scm2.REACTION_DONE)
+	}
+
+	return nil
+}
+
+// transitionIssue applies the transition unless the issue doesn't accept it anymore, so a retried
+// feedback whose transition was already applied goes on to copy the reply
+func transitionIssue(ctx context.Context, sonar *sonarqube2.Sonarqube, issue *sonarqube2.Issue, transition string) (bool, error) {
+	for _, available := range issue.Transitions {
+		if available == transition {
+			return true, sonar.TransitionIssueContext(ctx, issue.Key, transition)
+		}
+	}
+
+	logrus.Warnln("Transition", transition, "isn't available for", issue.Key, "it's already applied or not allowed")
+
+	return false, nil
+}
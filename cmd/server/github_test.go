@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v41/github"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncFeedback(t *testing.T) {
+	defer func(transitions map[string]string, resolve string) {
+		syncReplyTransitions, syncResolveTransition = transitions, resolve
+	}(syncReplyTransitions, syncResolveTransition)
+	syncReplyTransitions = map[string]string{"won't fix": "wontfix"}
+	syncResolveTransition = ""
+
+	tests := []struct {
+		name        string
+		permission  string
+		project     string
+		available   string
+		reply       string
+		transitions []string
+		comments    int
+		reactions   []string
+	}{
+		{
+			name:        "allowed reply",
+			permission:  "write",
+			available:   `["wontfix","falsepositive"]`,
+			reply:       "won't fix, it's generated",
+			transitions: []string{"wontfix"},
+			comments:    1,
+		},
+		{
+			name:        "allowed command",
+			permission:  "admin",
+			available:   `["wontfix","falsepositive"]`,
+			reply:       "/sqpr falsepositive",
+			transitions: []string{sonarqube2.TRANSITION_FALSE_POSITIVE},
+			comments:    1,
+			reactions:   []string{scm2.REACTION_DONE},
+		},
+		{
+			name:       "denied reply",
+			permission: "read",
+			available:  `["wontfix","falsepositive"]`,
+			reply:      "won't fix, it's generated",
+		},
+		{
+			name:       "denied command",
+			permission: "read",
+			available:  `["wontfix","falsepositive"]`,
+			reply:      "/sqpr falsepositive",
+			reactions:  []string{scm2.REACTION_DENIED},
+		},
+		{
+			name:       "issue of another project",
+			permission: "write",
+			project:    "someone_else",
+			available:  `["wontfix","falsepositive"]`,
+			reply:      "/sqpr falsepositive",
+			reactions:  []string{scm2.REACTION_DENIED},
+		},
+		{
+			name:       "retried after the transition",
+			permission: "write",
+			available:  `["reopen"]`,
+			reply:      "won't fix, it's generated",
+			comments:   1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.project == "" {
+				test.project = "herlon214_sonarqube-pr-issues"
+			}
+
+			var mu sync.Mutex
+			transitions := []string{}
+			comments := 0
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				switch r.URL.Path {
+				case "/api/issues/search":
+					w.Write([]byte(`{"total":1,"p":1,"ps":1,"issues":[{"key":"AX2GHjk1-Wk2ioy15Nrv","project":"` + test.project + `","status":"OPEN","transitions":` + test.available + `}]}`))
+				case "/api/issues/do_transition":
+					assert.Equal(t, "AX2GHjk1-Wk2ioy15Nrv", r.FormValue("issue"))
+					transitions = append(transitions, r.FormValue("transition"))
+				case "/api/issues/add_comment":
+					assert.Equal(t, "herlon214 replied in the PR:\n\n"+test.reply, r.FormValue("text"))
+					comments++
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer svr.Close()
+
+			version, err := sonarqube2.ParseVersion("9.9.0.65466")
+			assert.Nil(t, err)
+			sonar := sonarqube2.New(svr.URL, "my-key", sonarqube2.WithServerVersion(version))
+
+			reactions := []string{}
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatch(
+					mock.GetReposCollaboratorsPermissionByOwnerByRepoByUsername,
+					github.RepositoryPermissionLevel{Permission: github.String(test.permission)},
+				),
+				mock.WithRequestMatchHandler(
+					mock.PostReposPullsCommentsReactionsByOwnerByRepoByCommentId,
+					http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						var reaction github.Reaction
+						assert.Nil(t, json.NewDecoder(r.Body).Decode(&reaction))
+						reactions = append(reactions, reaction.GetContent())
+						w.Write(mock.MustMarshal(reaction))
+					}),
+				),
+			)
+			gh := scm2.NewGithub(context.Background(), sonar, "mytoken", scm2.WithTransport(mockedHTTPClient.Transport))
+
+			err = SyncFeedback(context.Background(), sonar, gh, &scm2.ThreadFeedback{
+				Owner:     "herlon214",
+				Repo:      "sonarqube-pr-issues",
+				User:      "herlon214",
+				IssueKey:  "AX2GHjk1-Wk2ioy15Nrv",
+				CommentID: 45,
+				Reply:     test.reply,
+			})
+			assert.Nil(t, err)
+
+			if test.transitions == nil {
+				test.transitions = []string{}
+			}
+			if test.reactions == nil {
+				test.reactions = []string{}
+			}
+			assert.Equal(t, test.transitions, transitions)
+			assert.Equal(t, test.comments, comments)
+			assert.Equal(t, test.reactions, reactions)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CHECK_OK    = "ok"
+	CHECK_ERROR = "error"
+)
+
+// readinessTimeout limits how long each dependency check can take
+const readinessTimeout = 5 * time.Second
+
+// Check is the result of a dependency check
+type Check struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Remaining is the GitHub API rate left
+	Remaining *int `json:"remaining,omitempty"`
+	// Depth and Capacity are the jobs waiting for a worker and how many can wait
+	Depth    *int `json:"depth,omitempty"`
+	Capacity *int `json:"capacity,omitempty"`
+}
+
+// Readiness is the breakdown of the dependency checks
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]*Check `json:"checks"`
+}
+
+// HealthHandler answers while the process is alive
+func HealthHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": CHECK_OK})
+}
+
+// ReadinessHandler checks the Sonarqube token, the GitHub credentials and the queue saturation,
+// answering 503 with the failed checks when sqpr can't process new webhooks
+func ReadinessHandler(sonar *sonarqube2.Sonarqube, gh *scm2.Github, jobs *queue.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), readinessTimeout)
+		defer cancel()
+
+		readiness := &Readiness{
+			Status: CHECK_OK,
+			Checks: map[string]*Check{
+				"sonarqube": checkSonarqube(ctx, sonar),
+				"github":    checkGithub(ctx, gh),
+				"queue":     checkQueue(jobs),
+			},
+		}
+
+		status := http.StatusOK
+		for name, check := range readiness.Checks {
+			if check.Status != CHECK_OK {
+				logrus.WithField("error", check.Error).Warnln("Readiness check", name, "failed")
+				readiness.Status = CHECK_ERROR
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		writeJSON(w, status, readiness)
+	}
+}
+
+// checkSonarqube validates the Sonarqube token
+func checkSonarqube(ctx context.Context, sonar *sonarqube2.Sonarqube) *Check {
+	valid, err := sonar.ValidateTokenContext(ctx)
+	if err != nil {
+		return &Check{Status: CHECK_ERROR, Error: err.Error()}
+	}
+	if !valid {
+		return &Check{Status: CHECK_ERROR, Error: "invalid token"}
+	}
+
+	return &Check{Status: CHECK_OK}
+}
+
+// checkGithub validates the GitHub token and that its API rate isn't exhausted
+func checkGithub(ctx context.Context, gh *scm2.Github) *Check {
+	rate, err := gh.CheckCredentials(ctx)
+	if err != nil {
+		return &Check{Status: CHECK_ERROR, Error: err.Error()}
+	}
+
+	remaining := rate.Remaining
+	if remaining == 0 {
+		return &Check{Status: CHECK_ERROR, Error: "API rate limit exhausted until " + rate.Reset.Format(time.RFC3339), Remaining: &remaining}
+	}
+
+	return &Check{Status: CHECK_OK, Remaining: &remaining}
+}
+
+// checkQueue fails when new webhooks would be refused
+func checkQueue(jobs *queue.Queue) *Check {
+	depth := jobs.Depth()
+	capacity := jobs.Capacity()
+	check := &Check{Status: CHECK_OK, Depth: &depth, Capacity: &capacity}
+
+	switch {
+	case jobs.Closed():
+		check.Status = CHECK_ERROR
+		check.Error = "shutting down"
+	case depth >= capacity:
+		check.Status = CHECK_ERROR
+		check.Error = "queue is full"
+	}
+
+	return check
+}
+
+// writeJSON answers with the given status and value
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logrus.WithError(err).Warnln("Failed to write the response")
+	}
+}
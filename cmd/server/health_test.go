@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	res := httptest.NewRecorder()
+	HealthHandler(res, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, res.Body.String())
+}
+
+func TestReadinessHandler(t *testing.T) {
+	ctx := context.Background()
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/authentication/validate", r.URL.Path)
+		w.Write([]byte(`{"valid":false}`))
+	}))
+	defer svr.Close()
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetRateLimit,
+			map[string]interface{}{"resources": github.RateLimits{Core: &github.Rate{Limit: 5000, Remaining: 4999}}},
+		),
+	)
+
+	version, err := sonarqube2.ParseVersion("9.9.0.65466")
+	assert.NoError(t, err)
+	sonar := sonarqube2.New(svr.URL, "myapikey", sonarqube2.WithServerVersion(version))
+	gh := scm2.NewGithub(ctx, sonar, "mytoken", scm2.WithTransport(mockedHTTPClient.Transport))
+	jobs := queue.New(queue.NewMemoryStore(), nil, queue.WithBacklog(5))
+
+	res := httptest.NewRecorder()
+	ReadinessHandler(sonar, gh, jobs)(res, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+
+	var readiness Readiness
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &readiness))
+	assert.Equal(t, CHECK_ERROR, readiness.Status)
+	assert.Equal(t, CHECK_ERROR, readiness.Checks["sonarqube"].Status)
+	assert.Equal(t, "invalid token", readiness.Checks["sonarqube"].Error)
+	assert.Equal(t, CHECK_OK, readiness.Checks["github"].Status)
+	assert.Equal(t, 4999, *readiness.Checks["github"].Remaining)
+	assert.Equal(t, CHECK_OK, readiness.Checks["queue"].Status)
+	assert.Equal(t, 5, *readiness.Checks["queue"].Capacity)
+}
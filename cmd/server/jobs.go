@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	JOB_PUBLISH  = "publish"
+	JOB_FEEDBACK = "feedback"
+	JOB_COMMAND  = "command"
+)
+
+// NewPublishJob creates a job to publish the issues of the given project branch, keyed by
+// the project and PR or branch so the webhooks of rapid pushes are coalesced into a single review
+func NewPublishJob(webhook sonarqube2.WebhookData) *queue.Job {
+	job := NewBranchPublishJob(webhook.Project.Key, webhook.BranchName(), webhook.Branch.Type)
+	job.Revision = webhook.Revision
+
+	return job
+}
+
+// NewBranchPublishJob creates a job to publish the issues of the given project branch or PR key
+func NewBranchPublishJob(project string, branch string, branchType string) *queue.Job {
+	job := queue.NewJob(JOB_PUBLISH)
+	job.Key = publishKey(project, branch)
+	job.Project = project
+	job.Branch = branch
+	job.BranchType = branchType
+
+	return job
+}
+
+// NewCommandJob creates a job to run the given slash command, republishing is keyed by the PR
+// so it never runs alongside a publish of the same PR
+func NewCommandJob(command *scm2.CommandEvent) (*queue.Job, error) {
+	job, err := queue.NewPayloadJob(JOB_COMMAND, command)
+	if err != nil {
+		return nil, err
+	}
+
+	if command.Command.Name == scm2.COMMAND_REPUBLISH {
+		job.Key = publishKey(projectFor(command.Owner, command.Repo), strconv.Itoa(command.PRNumber))
+	}
+
+	return job, nil
+}
+
+// publishKey is the key of the jobs that publish in the given PR
+func publishKey(project string, prKey string) string {
+	return fmt.Sprintf("%s/%s", project, prKey)
+}
+
+// HandleJob processes the queue jobs, each attempt has its own deadline
+func HandleJob(sonar *sonarqube2.Sonarqube, gh *scm2.Github) queue.Handler {
+	return func(ctx context.Context, job *queue.Job) error {
+		ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+		defer cancel()
+
+		switch job.Kind {
+		case JOB_PUBLISH:
+			logrus.Infoln("Processing", job.Project, "->", job.Branch)
+			report, err := PublishIssues(ctx, sonar, gh, job.Project, job.Branch, job.BranchType, false)
+			if resultErr := job.SetResult(report); resultErr != nil {
+				logrus.WithError(resultErr).Warnln("Failed to keep the publish report")
+			}
+			if err != nil {
+				return err
+			}
+
+			if report.Nothing != "" {
+				logrus.Infoln("Nothing published for", job.Project, job.Branch, "as", report.Nothing)
+
+				return nil
+			}
+			logrus.Infoln("Issues published for", job.Project, job.Branch)
+
+			return nil
+		case JOB_FEEDBACK:
+			var feedback scm2.ThreadFeedback
+			if err := job.Decode(&feedback); err != nil {
+				return err
+			}
+
+			return SyncFeedback(ctx, sonar, gh, &feedback)
+		case JOB_COMMAND:
+			var command scm2.CommandEvent
+			if err := job.Decode(&command); err != nil {
+				return err
+			}
+
+			return RunCommand(ctx, sonar, gh, &command)
+		}
+
+		return errors.New(fmt.Sprintf("unknown job kind %s", job.Kind))
+	}
+}
+
+// isRetryable checks if the failed job may succeed in a later attempt, invalid credentials,
+// missing PRs and reviews without relevant issues fail the same way every time
+func isRetryable(err error) bool {
+	if errors.Is(err, scm2.ErrNoRelevantIssues) {
+		return false
+	}
+
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		switch ghErr.Response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity:
+			return false
+		}
+	}
+
+	return sonarqube2.IsRetryable(err)
+}
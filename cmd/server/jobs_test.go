@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v41/github"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCommandJob(t *testing.T) {
+	republish, err := NewCommandJob(&scm2.CommandEvent{Owner: "herlon214", Repo: "sonarqube-pr-issues", PRNumber: 3, Command: &scm2.Command{Name: scm2.COMMAND_REPUBLISH}})
+	assert.NoError(t, err)
+	assert.Equal(t, JOB_COMMAND, republish.Kind)
+	// Same key as the publish jobs of the PR
+	assert.Equal(t, NewBranchPublishJob("herlon214_sonarqube-pr-issues", "3", "PULL_REQUEST").Key, republish.Key)
+
+	summary, err := NewCommandJob(&scm2.CommandEvent{Owner: "herlon214", Repo: "sonarqube-pr-issues", PRNumber: 3, Command: &scm2.Command{Name: scm2.COMMAND_SUMMARY}})
+	assert.NoError(t, err)
+	assert.Empty(t, summary.Key)
+}
+
+func TestIsRetryable(t *testing.T) {
+	githubErr := func(status int) error {
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: status, Request: &http.Request{}}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no relevant issues", errors.Wrap(scm2.ErrNoRelevantIssues, "failed to publish"), false},
+		{"github unauthorized", githubErr(http.StatusUnauthorized), false},
+		{"github forbidden", githubErr(http.StatusForbidden), false},
+		{"github not found", errors.Wrap(githubErr(http.StatusNotFound), "failed to get the diff"), false},
+		{"github unprocessable", githubErr(http.StatusUnprocessableEntity), false},
+		{"github unavailable", githubErr(http.StatusBadGateway), true},
+		{"github rate limit", &github.RateLimitError{Response: &http.Response{StatusCode: http.StatusForbidden, Request: &http.Request{}}}, true},
+		{"sonarqube not found", errors.Wrap(sonarqube2.ErrNotFound, "failed to find PR"), false},
+		{"sonarqube bad request", &sonarqube2.APIError{StatusCode: http.StatusBadRequest}, false},
+		{"sonarqube unauthorized", &sonarqube2.APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"sonarqube rate limit", &sonarqube2.APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"sonarqube unavailable", errors.Wrap(&sonarqube2.APIError{StatusCode: http.StatusServiceUnavailable}, "failed to list issues"), true},
+		{"timeout", errors.Wrap(context.DeadlineExceeded, "failed to list issues"), true},
+		{"cancelled", errors.Wrap(context.Canceled, "failed to list issues"), false},
+		{"unknown", errors.New("connection reset by peer"), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, isRetryable(test.err))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// publishLocks serializes the publishes of each PR, the queue only serializes the jobs of the same key
+// and a branch analysis is keyed by its branch until the worker finds its PR
+var publishLocks = newKeyedLock()
+
+// keyedLock is a mutex for each key, kept only while it's held or waited for
+type keyedLock struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	held chan struct{}
+	refs int
+}
+
+func newKeyedLock() *keyedLock {
+	return &keyedLock{locks: make(map[string]*keyLock)}
+}
+
+// Lock waits until the given key is free or the context is done, the returned function releases it
+func (k *keyedLock) Lock(ctx context.Context, key string) (func(), error) {
+	k.mu.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyLock{held: make(chan struct{}, 1)}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+		return func() {
+			<-lock.held
+			k.release(key, lock)
+		}, nil
+	case <-ctx.Done():
+		k.release(key, lock)
+
+		return nil, ctx.Err()
+	}
+}
+
+// release forgets the key once nobody holds or waits for it
+func (k *keyedLock) release(key string, lock *keyLock) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	lock.refs--
+	if lock.refs == 0 {
+		delete(k.locks, key)
+	}
+}
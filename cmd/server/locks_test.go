@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyedLock(t *testing.T) {
+	locks := newKeyedLock()
+
+	unlock, err := locks.Lock(context.Background(), "myproject/3")
+	assert.NoError(t, err)
+
+	// Other keys aren't held
+	unlockOther, err := locks.Lock(context.Background(), "myproject/4")
+	assert.NoError(t, err)
+	unlockOther()
+
+	// The same key waits until it's released or the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = locks.Lock(ctx, "myproject/3")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	unlock()
+	unlock, err = locks.Lock(context.Background(), "myproject/3")
+	assert.NoError(t, err)
+	unlock()
+
+	assert.Empty(t, locks.locks)
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	WEBHOOK_SOURCE_SONARQUBE = "sonarqube"
+	WEBHOOK_SOURCE_GITHUB    = "github"
+
+	REJECT_MISSING_SIGNATURE = "missing_signature"
+	REJECT_BAD_SIGNATURE     = "bad_signature"
+	REJECT_BAD_BODY          = "bad_body"
+	REJECT_BAD_JSON          = "bad_json"
+	REJECT_QUEUE_FULL        = "queue_full"
+	REJECT_SHUTTING_DOWN     = "shutting_down"
+	REJECT_ERROR             = "error"
+)
+
+var (
+	webhooksReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqpr_webhooks_received_total",
+		Help: "Webhooks received by source",
+	}, []string{"source"})
+	webhooksRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sqpr_webhooks_rejected_total",
+		Help: "Webhooks refused by source and reason",
+	}, []string{"source", "reason"})
+	issuesFetched = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sqpr_issues_fetched_total",
+		Help: "Open issues fetched from Sonarqube to publish",
+	})
+	issuesPublished = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sqpr_issues_published_total",
+		Help: "Issues commented in the PR reviews",
+	})
+	issuesSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sqpr_issues_skipped_outside_diff_total",
+		Help: "Issues not published as they aren't part of the PR diff",
+	})
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sqpr_api_request_duration_seconds",
+		Help:    "Duration of the Sonarqube and SCM API requests by endpoint and status",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"api", "endpoint", "status"})
+)
+
+// rejectWebhook answers the webhook with the given status, counting the reason it was refused
+func rejectWebhook(w http.ResponseWriter, source string, reason string, status int) {
+	webhooksRejected.WithLabelValues(source, reason).Inc()
+	deliveries.Record(Delivery{At: time.Now(), Source: source, Status: status, Reason: reason})
+	w.WriteHeader(status)
+}
+
+// instrumented observes the duration of the requests to the given API
+func instrumented(api string, rt http.RoundTripper) http.RoundTripper {
+	return transport.Instrument(rt, func(_ *http.Request, endpoint string, status string, duration time.Duration) {
+		apiRequestDuration.WithLabelValues(api, endpoint, status).Observe(duration.Seconds())
+	})
+}
+
+// MetricsHandler exposes the server metrics in the Prometheus format
+func MetricsHandler(sonar *sonarqube2.Sonarqube, jobs *queue.Queue) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		webhooksReceived,
+		webhooksRejected,
+		issuesFetched,
+		issuesPublished,
+		issuesSkipped,
+		apiRequestDuration,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "sqpr_jobs_processed_total",
+			Help: "Jobs finished successfully",
+		}, func() float64 { return float64(jobs.Processed()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "sqpr_jobs_failed_total",
+			Help: "Jobs that failed permanently and were moved to the dead letters",
+		}, func() float64 { return float64(jobs.Failed()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "sqpr_jobs_retried_total",
+			Help: "Failed job attempts scheduled to run again",
+		}, func() float64 { return float64(jobs.Retried()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "sqpr_sonarqube_retries_total",
+			Help: "Sonarqube requests retried after a temporary failure",
+		}, func() float64 { return float64(sonar.Retries()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "sqpr_queue_depth",
+			Help: "Jobs waiting for a worker",
+		}, func() float64 { return float64(jobs.Depth()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "sqpr_queue_capacity",
+			Help: "Jobs that can wait for a worker before the webhooks are refused",
+		}, func() float64 { return float64(jobs.Capacity()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "sqpr_queue_scheduled",
+			Help: "Failed jobs waiting for their next attempt",
+		}, func() float64 { return float64(jobs.Scheduled()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "sqpr_workers",
+			Help: "Workers processing the queue",
+		}, func() float64 { return float64(jobs.Workers()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "sqpr_workers_busy",
+			Help: "Workers processing a job",
+		}, func() float64 { return float64(jobs.Busy()) }),
+	)
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	sonar := sonarqube2.New("root", "key")
+	jobs := queue.New(queue.NewMemoryStore(), nil, queue.WithBacklog(5))
+	handler := WebhookHandler("mysecret", jobs)
+
+	// The counters are global, other tests and runs also count
+	rejected := webhooksRejected.WithLabelValues(WEBHOOK_SOURCE_SONARQUBE, REJECT_BAD_SIGNATURE)
+	rejectedBefore := testutil.ToFloat64(rejected)
+
+	// Bad signature
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("X-Sonar-Webhook-HMAC-SHA256", "invalid")
+	res := httptest.NewRecorder()
+	handler(res, req)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Equal(t, rejectedBefore+1, testutil.ToFloat64(rejected))
+
+	svr := httptest.NewServer(MetricsHandler(sonar, jobs))
+	defer svr.Close()
+
+	metricsRes, err := http.Get(svr.URL)
+	assert.NoError(t, err)
+	defer metricsRes.Body.Close()
+
+	body, err := io.ReadAll(metricsRes.Body)
+	assert.NoError(t, err)
+
+	assert.Contains(t, string(body), `sqpr_webhooks_received_total{source="sonarqube"}`)
+	assert.Contains(t, string(body), `sqpr_webhooks_rejected_total{reason="bad_signature",source="sonarqube"}`)
+	assert.Contains(t, string(body), "sqpr_queue_capacity 5")
+	assert.Contains(t, string(body), "sqpr_jobs_processed_total 0")
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+)
+
+const (
+	SKIP_NOT_OPEN          = "not open"
+	SKIP_ALREADY_PUBLISHED = "already published"
+	SKIP_OUTSIDE_DIFF      = "outside the PR diff"
+
+	NOTHING_NO_PR              = "the branch has no PR"
+	NOTHING_NO_ISSUES          = "no issues to publish"
+	NOTHING_NO_RELEVANT_ISSUES = "none of the issues are part of the PR diff"
+)
+
+// PublishReport tells which issues were published in the PR and why the others were skipped
+type PublishReport struct {
+	PullRequest string         `json:"pullRequest,omitempty"`
+	ReviewURL   string         `json:"reviewUrl,omitempty"`
+	Published   []string       `json:"published"`
+	Skipped     []SkippedIssue `json:"skipped"`
+	// Nothing tells why no review was published, empty when it was
+	Nothing string `json:"nothing,omitempty"`
+}
+
+// SkippedIssue is an issue that wasn't published
+type SkippedIssue struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+// NewPublishReport creates an empty report
+func NewPublishReport() *PublishReport {
+	return &PublishReport{Published: make([]string, 0), Skipped: make([]SkippedIssue, 0)}
+}
+
+// skip adds the issues of all that aren't in kept as skipped for the given reason
+func (r *PublishReport) skip(all *sonarqube2.Issues, kept *sonarqube2.Issues, reason string) {
+	keptKeys := make(map[string]bool, len(kept.Issues))
+	for _, issue := range kept.Issues {
+		keptKeys[issue.Key] = true
+	}
+
+	for _, issue := range all.Issues {
+		if !keptKeys[issue.Key] {
+			r.Skipped = append(r.Skipped, SkippedIssue{Key: issue.Key, Reason: reason})
+		}
+	}
+}
+
+// skipKeys adds the given issue keys as skipped for the given reason
+func (r *PublishReport) skipKeys(keys []string, reason string) {
+	for _, key := range keys {
+		r.Skipped = append(r.Skipped, SkippedIssue{Key: key, Reason: reason})
+	}
+}
@@ -0,0 +1,25 @@
+package server
+
+import (
+	"testing"
+
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishReport(t *testing.T) {
+	all := &sonarqube2.Issues{Issues: []sonarqube2.Issue{{Key: "closed"}, {Key: "published"}, {Key: "outside"}, {Key: "new"}}}
+	open := &sonarqube2.Issues{Issues: all.Issues[1:]}
+	unpublished := &sonarqube2.Issues{Issues: all.Issues[2:]}
+
+	report := NewPublishReport()
+	report.skip(all, open, SKIP_NOT_OPEN)
+	report.skip(open, unpublished, SKIP_ALREADY_PUBLISHED)
+	report.skipKeys([]string{"outside"}, SKIP_OUTSIDE_DIFF)
+
+	assert.Equal(t, []SkippedIssue{
+		{Key: "closed", Reason: SKIP_NOT_OPEN},
+		{Key: "published", Reason: SKIP_ALREADY_PUBLISHED},
+		{Key: "outside", Reason: SKIP_OUTSIDE_DIFF},
+	}, report.Skipped)
+}
@@ -7,15 +7,19 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/avast/retry-go"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
 	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
 	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/transport"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 )
 
@@ -42,8 +46,9 @@ func Run(cmd *cobra.Command, args []string) {
 
 		return
 	}
+	sonarOrganization := os.Getenv("SONAR_ORGANIZATION")
 	sonarRootURL := os.Getenv("SONAR_ROOT_URL")
-	if sonarRootURL == "" {
+	if sonarRootURL == "" && sonarOrganization == "" {
 		logrus.Panicln("SONAR_ROOT_URL environment variable is missing")
 
 		return
@@ -60,32 +65,121 @@ func Run(cmd *cobra.Command, args []string) {
 
 		return
 	}
+	ghWebhookSecret := os.Getenv("GH_WEBHOOK_SECRET")
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	dashboardPassword := os.Getenv("DASHBOARD_PASSWORD")
+
+	// HTTP transport shared by Sonarqube and the SCM
+	httpTransport, err := transport.New(transport.Config{CABundles: caBundles, ClientCert: clientCert, ClientKey: clientKey})
+	if err != nil {
+		logrus.WithError(err).Panicln("Failed to configure the HTTP transport")
+
+		return
+	}
 
 	// Sonarqube
-	sonar := sonarqube2.New(sonarRootURL, apiKey)
-	var gh scm2.SCM = scm2.NewGithub(ctx, sonar, ghToken)
+	sonarOpts := []sonarqube2.Option{sonarqube2.WithTimeout(sonarTimeout), sonarqube2.WithTransport(instrumented("sonarqube", httpTransport)), sonarqube2.WithRetry(sonarRetries, 500*time.Millisecond, 10*time.Second)}
+	if sonarOrganization != "" {
+		sonarOpts = append(sonarOpts, sonarqube2.WithSonarCloud(sonarOrganization))
+	}
+	sonar := sonarqube2.New(sonarRootURL, apiKey, sonarOpts...)
+	if version, err := sonar.VersionContext(ctx); err != nil {
+		logrus.WithError(err).Warnln("Failed to detect the Sonarqube version, using basic auth")
+	} else {
+		logrus.Infoln("Sonarqube version", version)
+	}
+	gh := scm2.NewGithub(
+		ctx, sonar, ghToken,
+		scm2.WithTransport(instrumented("github", httpTransport)),
+		scm2.WithSecondaryLocationComments(secondaryComments),
+		scm2.WithSummaryMeasures(summaryMetrics),
+	)
+
+	// Jobs are stored until finished so a restart doesn't lose them
+	var store queue.Store = queue.NewMemoryStore()
+	if queuePath != "" {
+		store, err = queue.NewBoltStore(queuePath)
+		if err != nil {
+			logrus.WithError(err).Panicln("Failed to open the queue file", queuePath, "set --queue-path to a writable path or to \"\" to keep the jobs in memory")
 
-	// Process queue
-	queue := make(chan func() error, 0)
-	for i := 0; i < workers; i++ {
-		go ProcessQueue(queue)
+			return
+		}
+	} else {
+		logrus.Warnln("The pending jobs are kept in memory only and lost on restart, set --queue-path to keep them in a file")
+	}
+	defer store.Close()
+
+	// Process queue, failed jobs wait for their next attempt without holding a worker and
+	// errors that won't go away by retrying, like an invalid token, fail straight away
+	jobs := queue.New(store, HandleJob(sonar, gh),
+		queue.WithBacklog(queueSize),
+		queue.WithRetry(jobAttempts, jobRetryDelay, jobRetryMaxDelay),
+		queue.WithRetryIf(isRetryable),
+		queue.WithHistory(historyRetention),
+	)
+	if err := jobs.Start(workers); err != nil {
+		logrus.WithError(err).Panicln("Failed to start the queue")
+
+		return
 	}
 
 	// Listen
-	http.HandleFunc("/webhook", WebhookHandler(webhookSecret, sonar, gh, queue))
+	http.HandleFunc("/webhook", WebhookHandler(webhookSecret, jobs))
+	http.Handle("/metrics", MetricsHandler(sonar, jobs))
+	http.HandleFunc("/healthz", HealthHandler)
+	http.HandleFunc("/readyz", ReadinessHandler(sonar, gh, jobs))
+	if ghWebhookSecret != "" {
+		http.HandleFunc("/github", GithubWebhookHandler(ghWebhookSecret, gh, jobs))
+	}
+	if adminToken != "" {
+		http.HandleFunc(ADMIN_PREFIX, AdminHandler(adminToken, jobs))
+	}
+	if dashboardPassword != "" {
+		http.HandleFunc("/dashboard", DashboardHandler(dashboardPassword, jobs))
+	}
 
+	server := &http.Server{Addr: fmt.Sprintf(":%d", serverPort)}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
 	logrus.Infoln("Listening on port", serverPort)
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", serverPort), nil); err != nil {
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
 		panic(err)
+	case sig := <-signals:
+		logrus.Infoln("Received", sig, "shutting down, waiting up to", shutdownGrace, "for the running jobs")
 	}
+
+	// Stop accepting webhooks, the senders retry them or they're published by the next analysis
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logrus.WithError(err).Warnln("Failed to close the open connections")
+	}
+
+	// Drain the running jobs, the others are kept in the store for the next start
+	if err := jobs.Shutdown(shutdownCtx); err != nil {
+		logrus.WithError(err).Warnln("Interrupted the running jobs")
+	}
+
+	logrus.Infoln("Server stopped")
 }
 
-func WebhookHandler(webhookSecret string, sonar *sonarqube2.Sonarqube, gh scm2.SCM, queue chan<- func() error) http.HandlerFunc {
+// WebhookHandler receives the Sonarqube webhooks and queues the publishing, answering fast since
+// if the request takes more than 10s Sonarqube shows the message 'Server Unreachable'
+func WebhookHandler(webhookSecret string, jobs *queue.Queue) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
+		webhooksReceived.WithLabelValues(WEBHOOK_SOURCE_SONARQUBE).Inc()
+
 		// Read webhook secret
 		reqSecret := req.Header.Get("X-Sonar-Webhook-HMAC-SHA256")
 		if reqSecret == "" {
-			w.WriteHeader(http.StatusUnauthorized)
+			rejectWebhook(w, WEBHOOK_SOURCE_SONARQUBE, REJECT_MISSING_SIGNATURE, http.StatusUnauthorized)
 
 			return
 		}
@@ -93,7 +187,7 @@ func WebhookHandler(webhookSecret string, sonar *sonarqube2.Sonarqube, gh scm2.S
 		// Read request body
 		body, err := io.ReadAll(req.Body)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			rejectWebhook(w, WEBHOOK_SOURCE_SONARQUBE, REJECT_BAD_BODY, http.StatusBadRequest)
 
 			return
 		}
@@ -109,7 +203,7 @@ func WebhookHandler(webhookSecret string, sonar *sonarqube2.Sonarqube, gh scm2.S
 
 		// Compare hashes
 		if sha != reqSecret {
-			w.WriteHeader(http.StatusUnauthorized)
+			rejectWebhook(w, WEBHOOK_SOURCE_SONARQUBE, REJECT_BAD_SIGNATURE, http.StatusUnauthorized)
 
 			return
 		}
@@ -118,81 +212,165 @@ func WebhookHandler(webhookSecret string, sonar *sonarqube2.Sonarqube, gh scm2.S
 		var webhook sonarqube2.WebhookData
 		err = json.Unmarshal(body, &webhook)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+			rejectWebhook(w, WEBHOOK_SOURCE_SONARQUBE, REJECT_BAD_JSON, http.StatusBadRequest)
 
 			return
 		}
 
 		// Add event to queue
-		logrus.Infoln("Adding to the queue", webhook.Project.Key, "->", webhook.Branch.Name)
-		queue <- func() error {
-			logrus.Infoln("Processing", webhook.Project.Key, "->", webhook.Branch.Name)
+		job := NewPublishJob(webhook)
+		logrus.WithField("depth", jobs.Depth()).Infoln("Adding to the queue", job.Project, "->", job.Branch)
+		if err := jobs.Enqueue(job); err != nil {
+			enqueueFailed(w, WEBHOOK_SOURCE_SONARQUBE, jobs, job, err)
 
-			if err := PublishIssues(context.Background(), sonar, gh, webhook.Project.Key, webhook.Branch.Name, webhook.Branch.Type); err != nil {
-				return err
-			}
-
-			logrus.Infoln("Issues published for", webhook.Project.Key, webhook.Branch.Name)
-
-			return nil
+			return
 		}
 
-		w.WriteHeader(http.StatusOK)
+		acceptWebhook(w, WEBHOOK_SOURCE_SONARQUBE, fmt.Sprintf("%s -> %s", job.Project, job.Branch))
 	}
 }
 
-// ProcessQueue is made to process the webhooks in background
-// if the request takes more than 10s Sonarqube shows the message 'Server Unreachable'
-func ProcessQueue(queue <-chan func() error) {
-	for fn := range queue {
-		err := retry.Do(fn, retry.Delay(time.Minute), retry.DelayType(retry.FixedDelay), retry.Attempts(5))
-		if err != nil {
-			logrus.WithError(err).Errorln("Failed to process webhook")
-		}
+// enqueueFailed answers the request whose job couldn't be queued, asking to retry later when the queue is full or shutting down
+func enqueueFailed(w http.ResponseWriter, source string, jobs *queue.Queue, job *queue.Job, err error) {
+	if errors.Is(err, queue.ErrQueueClosed) {
+		logrus.Warnln("Shutting down, refusing", job.Kind, "job")
+		rejectWebhook(w, source, REJECT_SHUTTING_DOWN, http.StatusServiceUnavailable)
+
+		return
 	}
+	if errors.Is(err, queue.ErrQueueFull) {
+		logrus.WithField("depth", jobs.Depth()).WithField("capacity", jobs.Capacity()).Warnln("Queue is full, refusing", job.Kind, "job")
+		w.Header().Set("Retry-After", strconv.Itoa(int(queueFullRetryAfter.Seconds())))
+		rejectWebhook(w, source, REJECT_QUEUE_FULL, http.StatusServiceUnavailable)
+
+		return
+	}
+
+	logrus.WithError(err).Errorln("Failed to enqueue", job.Kind, "job")
+	rejectWebhook(w, source, REJECT_ERROR, http.StatusInternalServerError)
 }
 
-// PublishIssues publishes the issues in the PR for the given project branch
-func PublishIssues(ctx context.Context, sonar *sonarqube2.Sonarqube, projectScm scm2.SCM, project string, branch string, branchType string) error {
+// PublishIssues publishes the issues in the PR for the given project branch, republish includes the issues already published.
+// The report tells which issues were published and why the others weren't, even when it fails
+func PublishIssues(ctx context.Context, sonar *sonarqube2.Sonarqube, projectScm scm2.SCM, project string, branch string, branchType string, republish bool) (*PublishReport, error) {
+	report := NewPublishReport()
+
 	// Find PR
 	var pr *sonarqube2.PullRequest
 	var err error
 	if branchType == sonarqube2.BRANCH_TYPE_PULL_REQUEST {
-		pr, err = sonar.FindPRForKey(project, branch)
+		pr, err = sonar.FindPRForKeyContext(ctx, project, branch)
 		if err != nil {
-			return errors.Wrap(err, fmt.Sprintf("failed to find PR for key %s of the project %s", branch, project))
+			return report, errors.Wrap(err, fmt.Sprintf("failed to find PR for key %s of the project %s", branch, project))
 		}
 	} else {
-		pr, err = sonar.FindPRForBranch(project, branch)
+		pr, err = sonar.FindPRForBranchContext(ctx, project, branch)
+		if errors.Is(err, sonarqube2.ErrNotFound) {
+			logrus.Infoln("No PR for branch", branch, "of the project", project)
+			report.Nothing = NOTHING_NO_PR
+
+			return report, nil
+		}
+		if err != nil {
+			return report, errors.Wrap(err, fmt.Sprintf("failed to find PR for branch %s of the project %s", branch, project))
+		}
+	}
+	report.PullRequest = pr.Key
+
+	// A branch analysis and the PR analysis of the same push run as different jobs, only one publishes at a time
+	unlock, err := publishLocks.Lock(ctx, publishKey(project, pr.Key))
+	if err != nil {
+		return report, errors.Wrap(err, fmt.Sprintf("failed to wait for the running publish of PR %s of the project %s", pr.Key, project))
+	}
+	defer unlock()
+
+	// Publish hotspots
+	if publishHotspots {
+		hotspots, err := sonar.ListHotspotsForPRContext(ctx, project, pr.Key)
 		if err != nil {
-			return errors.Wrap(err, fmt.Sprintf("failed to find PR for branch %s of the project %s", branch, project))
+			return report, errors.Wrap(err, fmt.Sprintf("failed to list hotspots for the given PR branch %s of the project %s", branch, project))
+		}
+
+		err = projectScm.PublishHotspotsReviewFor(ctx, hotspots.FilterByStatus(sonarqube2.HOTSPOT_STATUS_TO_REVIEW).Hotspots, pr)
+		if err != nil {
+			return report, errors.Wrap(err, fmt.Sprintf("failed to publish hotspots review for branch %s of the project %s", branch, project))
 		}
 	}
 
+	// Publish uncovered lines
+	if publishCoverage {
+		err = projectScm.PublishCoverageReviewFor(ctx, project, pr, coverageMaxComments)
+		if err != nil {
+			return report, errors.Wrap(err, fmt.Sprintf("failed to publish coverage review for branch %s of the project %s", branch, project))
+		}
+	}
+
+	// Publish duplicated blocks
+	if publishDuplications {
+		err = projectScm.PublishDuplicationsReviewFor(ctx, project, pr)
+		if err != nil {
+			return report, errors.Wrap(err, fmt.Sprintf("failed to publish duplications review for branch %s of the project %s", branch, project))
+		}
+	}
+
+	// Summarize the PR measures, the issues are still worth publishing without them
+	err = projectScm.PublishSummaryFor(ctx, project, pr)
+	if err != nil {
+		logrus.WithError(err).Warnln("Failed to publish the measures summary for branch", branch, "of the project", project)
+	}
+
 	// List issues
-	issues, err := sonar.ListIssuesForPR(project, pr.Key)
+	issues, err := sonar.ListIssuesForPRContext(ctx, project, pr.Key)
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to list issues for the given PR branch %s of the project %s", branch, project))
+		return report, errors.Wrap(err, fmt.Sprintf("failed to list issues for the given PR branch %s of the project %s", branch, project))
 	}
 
 	// Filter issues
-	issues = issues.FilterByStatus("OPEN").FilterOutByTag(sonarqube2.TAG_PUBLISHED)
+	marker := sonarqube2.PublishMarker{Tag: publishTag, Transition: publishTransition, Comment: publishComment}
+	openIssues := issues.FilterByStatus("OPEN")
+	report.skip(issues, openIssues, SKIP_NOT_OPEN)
+	issuesFetched.Add(float64(len(openIssues.Issues)))
+	issues = openIssues
+	if !republish {
+		issues = marker.Unpublished(openIssues)
+		report.skip(openIssues, issues, SKIP_ALREADY_PUBLISHED)
+	}
 
 	// No issues found
 	if len(issues.Issues) == 0 {
-		return nil
+		report.Nothing = NOTHING_NO_ISSUES
+
+		return report, nil
 	}
 
 	// Publish review
-	err = projectScm.PublishIssuesReviewFor(ctx, issues.Issues, pr, requestChanges)
+	review, err := projectScm.PublishIssuesReviewFor(ctx, issues.Issues, pr, requestChanges)
+	if errors.Is(err, scm2.ErrNoRelevantIssues) {
+		logrus.Infoln("None of the issues are part of the diff of PR", pr.Key, "of the project", project)
+		report.skip(issues, &sonarqube2.Issues{}, SKIP_OUTSIDE_DIFF)
+		report.Nothing = NOTHING_NO_RELEVANT_ISSUES
+		issuesSkipped.Add(float64(len(issues.Issues)))
+
+		return report, nil
+	}
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("Failed to publish issues review for branch %s of the project %s", branch, project))
+		return report, errors.Wrap(err, fmt.Sprintf("Failed to publish issues review for branch %s of the project %s", branch, project))
+	}
+	report.ReviewURL = review.URL
+	report.skipKeys(review.Skipped, SKIP_OUTSIDE_DIFF)
+	for _, issue := range review.Published(issues.Issues) {
+		report.Published = append(report.Published, issue.Key)
 	}
+	issuesSkipped.Add(float64(len(review.Skipped)))
+	issuesPublished.Add(float64(len(report.Published)))
 
 	// Tag published issues
-	bulkActionRes, err := sonar.TagIssues(issues.Issues, sonarqube2.TAG_PUBLISHED)
+	bulkActionRes, err := sonar.MarkIssuesContext(ctx, issues.Issues, marker)
+	if bulkActionRes != nil && len(bulkActionRes.FailedIssues) > 0 {
+		logrus.WithField("issues", bulkActionRes.FailedIssues).Warnln("Issues not marked as published")
+	}
 	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("failed to mark issues as published for branch %s of the project %s", branch, project))
+		return report, errors.Wrap(err, fmt.Sprintf("failed to mark issues as published for branch %s of the project %s", branch, project))
 	}
 
 	logrus.Infoln("--------------------------")
@@ -202,6 +380,15 @@ func PublishIssues(ctx context.Context, sonar *sonarqube2.Sonarqube, projectScm
 	logrus.Infoln(bulkActionRes.Failures, "issues failed")
 	logrus.Infoln("--------------------------")
 
-	return nil
+	// Link the issues to their PR discussion, not retried as the review is already published
+	if marker.Comment && review.URL != "" {
+		commentRes, err := sonar.CommentIssuesContext(ctx, review.Published(issues.Issues), func(issue sonarqube2.Issue) string {
+			return marker.IssueComment(review.URL, review.IssueComments[issue.Key])
+		})
+		if err != nil {
+			logrus.WithError(err).WithField("issues", commentRes.FailedIssues).Errorln("Failed to comment the PR discussion link in the issues")
+		}
+	}
 
+	return report, nil
 }
@@ -0,0 +1,318 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	scm2 "github.com/herlon214/sonarqube-pr-issues/pkg/scm"
+	sonarqube2 "github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+const prDiff = `diff --git a/pkg/my_file.go b/pkg/my_file.go
+index 5d90f4b..9521466 100644
+--- a/pkg/my_file.go
++++ b/pkg/my_file.go
+@@ -1,3 +1,4 @@
+ package pkg
+ 
++var unused = 1
+ var used = 2
+`
+
+func TestWebhookHandlerCoalescesConcurrentWebhooks(t *testing.T) {
+	defer func(tag string, hotspots bool) { publishTag, publishHotspots = tag, hotspots }(publishTag, publishHotspots)
+	publishTag = sonarqube2.TAG_PUBLISHED
+	publishHotspots = false
+
+	tests := []struct {
+		name           string
+		lookupFailures int32
+	}{
+		{name: "PR found"},
+		{name: "PR lookup fails", lookupFailures: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// Sonarqube tags the issue once it's published
+			var tagged, lookups int32
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/project_pull_requests/list":
+					if atomic.AddInt32(&lookups, 1) <= test.lookupFailures {
+						w.WriteHeader(http.StatusServiceUnavailable)
+
+						return
+					}
+					w.Write([]byte(`{"pullRequests":[{"key":"3","branch":"feat/newtest","url":"https://github.com/herlon214/sonarqube-pr-issues/pull/3"}]}`))
+				case "/api/issues/search":
+					// Slow enough for concurrent publishes to list the issue before it's tagged
+					time.Sleep(50 * time.Millisecond)
+
+					tags := `[]`
+					if atomic.LoadInt32(&tagged) == 1 {
+						tags = `["published"]`
+					}
+					w.Write([]byte(`{"total":1,"p":1,"ps":500,"issues":[{"key":"AX2GHjk1-Wk2ioy15Nrv","project":"myproject","component":"myproject:pkg/my_file.go","severity":"MAJOR","type":"CODE_SMELL","rule":"go:S1234","status":"OPEN","message":"Remove this unused variable","line":3,"tags":` + tags + `}]}`))
+				case "/api/issues/bulk_change":
+					atomic.StoreInt32(&tagged, 1)
+					w.Write([]byte(`{"total":1,"success":1,"ignored":0,"failures":0}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer svr.Close()
+
+			var reviews int32
+			mockedHTTPClient := mock.NewMockedHTTPClient(
+				mock.WithRequestMatchHandler(
+					mock.GetReposPullsByOwnerByRepoByPullNumber,
+					http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+						w.Write([]byte(prDiff))
+					}),
+				),
+				mock.WithRequestMatchHandler(
+					mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+					http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+						atomic.AddInt32(&reviews, 1)
+						w.Write(mock.MustMarshal(github.PullRequestReview{ID: github.Int64(80)}))
+					}),
+				),
+			)
+
+			version, err := sonarqube2.ParseVersion("9.9.0.65466")
+			assert.NoError(t, err)
+			sonar := sonarqube2.New(svr.URL, "myapikey", sonarqube2.WithServerVersion(version), sonarqube2.WithRetry(1, time.Millisecond, time.Millisecond))
+			gh := scm2.NewGithub(ctx, sonar, "mytoken", scm2.WithTransport(mockedHTTPClient.Transport))
+
+			// The jobs whose PR lookup failed are retried by the queue
+			store := queue.NewMemoryStore()
+			jobs := queue.New(store, HandleJob(sonar, gh), queue.WithRetry(5, 10*time.Millisecond, 50*time.Millisecond), queue.WithRetryIf(isRetryable))
+			assert.NoError(t, jobs.Start(4))
+
+			// Rapid pushes to the same PR, analysed as a PR and as a branch
+			bodies := [][]byte{
+				[]byte(`{"project":{"key":"myproject"},"branch":{"name":"3","type":"PULL_REQUEST"}}`),
+				[]byte(`{"project":{"key":"myproject"},"branch":{"name":"feat/newtest","type":"BRANCH"}}`),
+			}
+
+			handler := WebhookHandler("mysecret", jobs)
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				body := bodies[i%len(bodies)]
+				h := hmac.New(sha256.New, []byte("mysecret"))
+				h.Write(body)
+				signature := hex.EncodeToString(h.Sum(nil))
+
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+					req.Header.Set("X-Sonar-Webhook-HMAC-SHA256", signature)
+					res := httptest.NewRecorder()
+					handler(res, req)
+
+					assert.Equal(t, http.StatusOK, res.Code)
+				}()
+			}
+			wg.Wait()
+
+			assert.Eventually(t, func() bool {
+				pending, _ := store.List()
+
+				return len(pending) == 0
+			}, 5*time.Second, 10*time.Millisecond)
+
+			assert.Equal(t, int32(1), atomic.LoadInt32(&reviews))
+		})
+	}
+}
+
+func TestWebhookHandlerRefusesWhenQueueIsFull(t *testing.T) {
+	jobs := queue.New(queue.NewMemoryStore(), nil, queue.WithBacklog(1))
+	handler := WebhookHandler("mysecret", jobs)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		h := hmac.New(sha256.New, []byte("mysecret"))
+		h.Write([]byte(body))
+
+		req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader([]byte(body)))
+		req.Header.Set("X-Sonar-Webhook-HMAC-SHA256", hex.EncodeToString(h.Sum(nil)))
+		res := httptest.NewRecorder()
+		handler(res, req)
+
+		return res
+	}
+
+	// Without workers the first job takes the only slot
+	res := send(`{"project":{"key":"myproject"},"branch":{"name":"3","type":"PULL_REQUEST"}}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = send(`{"project":{"key":"myproject"},"branch":{"name":"4","type":"PULL_REQUEST"}}`)
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.Equal(t, strconv.Itoa(int(queueFullRetryAfter.Seconds())), res.Header().Get("Retry-After"))
+
+	// Merged into the waiting job of the same PR
+	res = send(`{"project":{"key":"myproject"},"branch":{"name":"3","type":"PULL_REQUEST"}}`)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// Shutting down isn't worth retrying soon
+	assert.NoError(t, jobs.Shutdown(context.Background()))
+	res = send(`{"project":{"key":"myproject"},"branch":{"name":"4","type":"PULL_REQUEST"}}`)
+	assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+	assert.Empty(t, res.Header().Get("Retry-After"))
+}
+
+func TestPublishIssuesWithNothingToPublish(t *testing.T) {
+	ctx := context.Background()
+
+	defer func(hotspots bool) { publishHotspots = hotspots }(publishHotspots)
+	publishHotspots = false
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/project_pull_requests/list":
+			w.Write([]byte(`{"pullRequests":[{"key":"3","branch":"feat/newtest","url":"https://github.com/herlon214/sonarqube-pr-issues/pull/3"}]}`))
+		case "/api/issues/search":
+			// Outside the PR diff
+			w.Write([]byte(`{"total":1,"p":1,"ps":500,"issues":[{"key":"AX2GHjk1-Wk2ioy15Nrv","project":"myproject","component":"myproject:pkg/my_file.go","severity":"MAJOR","type":"CODE_SMELL","rule":"go:S1234","status":"OPEN","message":"Remove this unused variable","line":40}]}`))
+		case "/api/measures/component":
+			w.Write([]byte(`{"component":{"key":"myproject","measures":[{"metric":"new_coverage","period":{"index":1,"value":"50.0"}}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	var summaries int32
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(prDiff))
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("sqpr-bot")},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			[]github.IssueComment{},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				atomic.AddInt32(&summaries, 1)
+				w.Write(mock.MustMarshal(github.IssueComment{ID: github.Int64(10)}))
+			}),
+		),
+	)
+
+	version, err := sonarqube2.ParseVersion("9.9.0.65466")
+	assert.NoError(t, err)
+	sonar := sonarqube2.New(svr.URL, "myapikey", sonarqube2.WithServerVersion(version))
+	gh := scm2.NewGithub(ctx, sonar, "mytoken", scm2.WithTransport(mockedHTTPClient.Transport), scm2.WithSummaryMeasures([]string{"new_coverage"}))
+
+	// A push to a branch without PR
+	report, err := PublishIssues(ctx, sonar, gh, "myproject", "main", "BRANCH", false)
+	assert.NoError(t, err)
+	assert.Equal(t, NOTHING_NO_PR, report.Nothing)
+
+	report, err = PublishIssues(ctx, sonar, gh, "myproject", "3", sonarqube2.BRANCH_TYPE_PULL_REQUEST, false)
+	assert.NoError(t, err)
+	assert.Equal(t, NOTHING_NO_RELEVANT_ISSUES, report.Nothing)
+	assert.Equal(t, []SkippedIssue{{Key: "AX2GHjk1-Wk2ioy15Nrv", Reason: SKIP_OUTSIDE_DIFF}}, report.Skipped)
+
+	// The measures are summarized even without issues to comment
+	assert.Equal(t, int32(1), atomic.LoadInt32(&summaries))
+}
+
+func TestPublishIssuesOnlyLinksThePublishedIssues(t *testing.T) {
+	ctx := context.Background()
+
+	defer func(tag string, comment bool, hotspots bool) {
+		publishTag, publishComment, publishHotspots = tag, comment, hotspots
+	}(publishTag, publishComment, publishHotspots)
+	publishTag = sonarqube2.TAG_PUBLISHED
+	publishComment = true
+	publishHotspots = false
+
+	var mutex sync.Mutex
+	commented := make([]string, 0)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/project_pull_requests/list":
+			w.Write([]byte(`{"pullRequests":[{"key":"3","branch":"feat/newtest","url":"https://github.com/herlon214/sonarqube-pr-issues/pull/3"}]}`))
+		case "/api/issues/search":
+			w.Write([]byte(`{"total":2,"p":1,"ps":500,"issues":[` +
+				`{"key":"AX2GHjk1-Wk2ioy15Nrv","project":"myproject","component":"myproject:pkg/my_file.go","severity":"MAJOR","type":"CODE_SMELL","rule":"go:S1234","status":"OPEN","message":"Remove this unused variable","line":3},` +
+				`{"key":"AX2GHjk1-Wk2ioy15Nrw","project":"myproject","component":"myproject:pkg/my_file.go","severity":"MAJOR","type":"CODE_SMELL","rule":"go:S1234","status":"OPEN","message":"Remove this unused variable","line":40}]}`))
+		case "/api/issues/bulk_change":
+			w.Write([]byte(`{"total":2,"success":2,"ignored":0,"failures":0}`))
+		case "/api/issues/add_comment":
+			assert.NoError(t, r.ParseForm())
+			mutex.Lock()
+			commented = append(commented, r.PostForm.Get("issue"))
+			mutex.Unlock()
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(prDiff))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(`{"id":80,"html_url":"https://github.com/herlon214/sonarqube-pr-issues/pull/3#pullrequestreview-80"}`))
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposPullsReviewsCommentsByOwnerByRepoByPullNumberByReviewId,
+			[]*github.PullRequestComment{
+				{
+					Body:    github.String("Remove this unused variable\n\n<!-- sqpr:issue:AX2GHjk1-Wk2ioy15Nrv -->"),
+					HTMLURL: github.String("https://github.com/herlon214/sonarqube-pr-issues/pull/3#discussion_r90"),
+				},
+			},
+		),
+	)
+
+	version, err := sonarqube2.ParseVersion("9.9.0.65466")
+	assert.NoError(t, err)
+	sonar := sonarqube2.New(svr.URL, "myapikey", sonarqube2.WithServerVersion(version))
+	gh := scm2.NewGithub(ctx, sonar, "mytoken", scm2.WithTransport(mockedHTTPClient.Transport))
+
+	report, err := PublishIssues(ctx, sonar, gh, "myproject", "3", sonarqube2.BRANCH_TYPE_PULL_REQUEST, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"AX2GHjk1-Wk2ioy15Nrv"}, report.Published)
+	assert.Equal(t, []SkippedIssue{{Key: "AX2GHjk1-Wk2ioy15Nrw", Reason: SKIP_OUTSIDE_DIFF}}, report.Skipped)
+
+	// The issue outside the diff isn't discussed in the PR
+	assert.Equal(t, []string{"AX2GHjk1-Wk2ioy15Nrv"}, commented)
+}
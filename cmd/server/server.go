@@ -1,12 +1,45 @@
 package server
 
 import (
+	"time"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/queue"
+	"github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
 	"github.com/spf13/cobra"
 )
 
 var serverPort int
 var workers int
 var requestChanges bool
+var secondaryComments bool
+var publishHotspots bool
+var publishCoverage bool
+var coverageMaxComments int
+var publishDuplications bool
+var summaryMetrics []string
+var sonarTimeout time.Duration
+var sonarRetries int
+var publishTag string
+var publishTransition string
+var publishComment bool
+var caBundles []string
+var clientCert string
+var clientKey string
+var jobTimeout time.Duration
+var syncReplyTransitions map[string]string
+var syncResolveTransition string
+var githubProjects map[string]string
+var ignoreTransition string
+var queuePath string
+var queueSize int
+var jobAttempts int
+var jobRetryDelay time.Duration
+var jobRetryMaxDelay time.Duration
+var shutdownGrace time.Duration
+var historyRetention time.Duration
+
+// queueFullRetryAfter is how long the webhook senders are asked to wait when the queue is full
+const queueFullRetryAfter = time.Minute
 
 var ServerCmd = &cobra.Command{
 	Use:   "server",
@@ -17,5 +50,31 @@ func init() {
 	ServerCmd.PersistentFlags().IntVarP(&serverPort, "port", "p", 8080, "Server port")
 	ServerCmd.PersistentFlags().IntVarP(&workers, "workers", "w", 30, "Workers count")
 	ServerCmd.PersistentFlags().BoolVar(&requestChanges, "request-changes", true, "When issue is found, mark PR as changes requested")
+	ServerCmd.PersistentFlags().StringVar(&publishTag, "publish-tag", sonarqube.TAG_PUBLISHED, "Tag added to the published issues so they aren't published again, empty to not tag them")
+	ServerCmd.PersistentFlags().StringVar(&publishTransition, "publish-transition", sonarqube.TRANSITION_SET_IN_REVIEW, "Transition applied to the published issues, empty to keep their status")
+	ServerCmd.PersistentFlags().BoolVar(&publishComment, "publish-comment", false, "Comment the link to the PR discussion in the published issues")
+	ServerCmd.PersistentFlags().BoolVar(&secondaryComments, "secondary-comments", false, "Also comment on the secondary locations of an issue that are part of the PR diff")
+	ServerCmd.PersistentFlags().BoolVar(&publishHotspots, "hotspots", true, "Publish the security hotspots to review in the PR")
+	ServerCmd.PersistentFlags().BoolVar(&publishCoverage, "coverage", false, "Comment on the new lines that are not covered by tests")
+	ServerCmd.PersistentFlags().IntVar(&coverageMaxComments, "coverage-max-comments", 10, "Maximum coverage comments in a PR")
+	ServerCmd.PersistentFlags().BoolVar(&publishDuplications, "duplications", false, "Comment on the duplicated blocks added by the PR")
+	ServerCmd.PersistentFlags().StringSliceVar(&summaryMetrics, "measures", sonarqube.DefaultPullRequestMetrics, "PR metrics summarized in a PR comment updated on each analysis, empty to disable")
+	ServerCmd.PersistentFlags().DurationVar(&sonarTimeout, "sonar-timeout", 10*time.Second, "Timeout of each Sonarqube request")
+	ServerCmd.PersistentFlags().IntVar(&sonarRetries, "sonar-retries", 3, "Attempts of each idempotent Sonarqube request that fails temporarily, 1 to disable the retries")
+	ServerCmd.PersistentFlags().StringSliceVar(&caBundles, "ca-bundle", nil, "PEM files with extra certificate authorities to trust")
+	ServerCmd.PersistentFlags().StringVar(&clientCert, "client-cert", "", "PEM file with the client certificate for mutual TLS")
+	ServerCmd.PersistentFlags().StringVar(&clientKey, "client-key", "", "PEM file with the client certificate key for mutual TLS")
+	ServerCmd.PersistentFlags().DurationVar(&jobTimeout, "job-timeout", 5*time.Minute, "Timeout of each attempt to publish a webhook")
+	ServerCmd.PersistentFlags().StringToStringVar(&syncReplyTransitions, "sync-reply-transitions", map[string]string{"false positive": "falsepositive", "won't fix": "wontfix", "accept": "accept"}, "Transition applied to the issue when a reply in its PR thread starts with the keyword")
+	ServerCmd.PersistentFlags().StringVar(&syncResolveTransition, "sync-resolve-transition", "", "Transition applied to the issue when its PR thread is resolved, empty to only sync the replies")
+	ServerCmd.PersistentFlags().StringToStringVar(&githubProjects, "github-projects", nil, "Sonarqube project of each GitHub repository for the slash commands, like owner/repo=project-key (default owner_repo)")
+	ServerCmd.PersistentFlags().StringVar(&ignoreTransition, "ignore-transition", "wontfix", "Transition applied to the issues ignored with the /sqpr ignore command")
+	ServerCmd.PersistentFlags().StringVar(&queuePath, "queue-path", "sqpr-queue.db", "File that keeps the pending jobs across restarts, like /data/sqpr-queue.db in a volume, set it empty to keep them in memory only")
+	ServerCmd.PersistentFlags().IntVar(&queueSize, "queue-size", queue.DEFAULT_BACKLOG, "Jobs waiting for a worker before the webhooks are refused with 503")
+	ServerCmd.PersistentFlags().IntVar(&jobAttempts, "job-attempts", 5, "Attempts of each job before it's moved to the dead letters")
+	ServerCmd.PersistentFlags().DurationVar(&jobRetryDelay, "job-retry-delay", time.Minute, "Delay before retrying a failed job, doubled on each attempt")
+	ServerCmd.PersistentFlags().DurationVar(&jobRetryMaxDelay, "job-retry-max-delay", 30*time.Minute, "Maximum delay before retrying a failed job")
+	ServerCmd.PersistentFlags().DurationVar(&shutdownGrace, "shutdown-grace", 25*time.Second, "Time given to the running jobs to finish on SIGTERM, keep it under the Kubernetes termination grace period")
+	ServerCmd.PersistentFlags().DurationVar(&historyRetention, "history-retention", 7*24*time.Hour, "How long the finished jobs and dead letters are kept, 0 to keep no history and the dead letters forever")
 	ServerCmd.AddCommand(RunCmd)
 }
@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrJobNotFound is returned when no job has the given ID
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobActive is returned when retrying a job that is already waiting or running
+var ErrJobActive = errors.New("job is already waiting or running")
+
+// ErrJobFinished is returned when cancelling a job that isn't waiting or running
+var ErrJobFinished = errors.New("job is already finished")
+
+// Jobs returns the waiting, running, dead and finished jobs, newest first
+func (q *Queue) Jobs() ([]*Job, error) {
+	active, err := q.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	dead, err := q.store.ListDead()
+	if err != nil {
+		return nil, err
+	}
+
+	finished, err := q.store.ListFinished()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := append(append(active, dead...), finished...)
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+
+	return jobs, nil
+}
+
+// Job returns the job with the given ID, looking it up in the active jobs, the dead letters and the history
+func (q *Queue) Job(id string) (*Job, error) {
+	for _, get := range []func(id string) (*Job, error){q.store.Get, q.store.GetDead, q.store.GetFinished} {
+		job, err := get(id)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return job, nil
+		}
+	}
+
+	return nil, ErrJobNotFound
+}
+
+// Retry runs the job with the given ID again: scheduled retries run now, dead letters get new attempts
+// and finished jobs are copied into a new job. The job that is going to run is returned
+func (q *Queue) Retry(id string) (*Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+
+	// Skip the backoff
+	if job := q.unscheduleID(id); job != nil {
+		job.Status = STATUS_PENDING
+		q.retryAt(job, time.Now())
+
+		return job, q.store.Put(job)
+	}
+
+	if _, ok := q.running[id]; ok {
+		return nil, ErrJobActive
+	}
+
+	active, err := q.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return nil, ErrJobActive
+	}
+
+	job, err := q.store.GetDead(id)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil {
+		if err := q.store.DeleteDead(id); err != nil {
+			return nil, err
+		}
+
+		return q.requeue(job)
+	}
+
+	job, err = q.store.GetFinished(id)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil {
+		// The history keeps the finished one
+		job.ID = newID()
+		job.CreatedAt = time.Now()
+
+		return q.requeue(job)
+	}
+
+	return nil, ErrJobNotFound
+}
+
+// Cancel stops the job with the given ID, the running jobs get their context cancelled
+func (q *Queue) Cancel(id string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if cancel, ok := q.running[id]; ok {
+		q.cancelled[id] = true
+		cancel()
+
+		return nil
+	}
+
+	if job := q.unscheduleID(id); job != nil {
+		if q.pending[job.pendingKey()] == job {
+			delete(q.pending, job.pendingKey())
+		}
+		q.finish(job, STATUS_CANCELLED)
+
+		return nil
+	}
+
+	active, err := q.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if active == nil {
+		return ErrJobFinished
+	}
+
+	// Waiting for a worker, it's dropped when taken
+	for key, job := range q.pending {
+		if job.ID == id {
+			delete(q.pending, key)
+		}
+	}
+	q.cancelled[id] = true
+
+	return nil
+}
+
+// requeue schedules the given job to run now with new attempts, merging it into the waiting job with the same key
+func (q *Queue) requeue(job *Job) (*Job, error) {
+	job.Status = STATUS_PENDING
+	job.Attempts = 0
+	job.LastError = ""
+	job.FinishedAt = time.Time{}
+
+	if pending, ok := q.pending[job.pendingKey()]; ok && job.Key != "" {
+		pending.replaceWith(job)
+
+		return pending, q.store.Put(pending)
+	}
+
+	if err := q.store.Put(job); err != nil {
+		return nil, err
+	}
+	if job.Key != "" {
+		q.pending[job.pendingKey()] = job
+	}
+	q.retryAt(job, time.Now())
+
+	return job, nil
+}
@@ -0,0 +1,177 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueJobsHistory(t *testing.T) {
+	store := NewMemoryStore()
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		return job.SetResult(map[string]int{"published": 2})
+	}, WithHistory(time.Hour))
+	assert.NoError(t, q.Start(1))
+
+	job := NewJob("publish")
+	assert.NoError(t, q.Enqueue(job))
+
+	assert.Eventually(t, func() bool {
+		found, err := q.Job(job.ID)
+
+		return err == nil && found.Status == STATUS_DONE
+	}, time.Second, 10*time.Millisecond)
+
+	found, err := q.Job(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, found.Attempts)
+	assert.JSONEq(t, `{"published":2}`, string(found.Result))
+	assert.False(t, found.FinishedAt.IsZero())
+
+	_, err = q.Job("missing")
+	assert.Equal(t, ErrJobNotFound, err)
+
+	// Expired
+	assert.NoError(t, store.PruneFinished(time.Now().Add(time.Minute)))
+	jobs, err := q.Jobs()
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestQueueRetryDeadLetter(t *testing.T) {
+	fail := true
+	done := make(chan *Job, 1)
+
+	q := New(NewMemoryStore(), func(ctx context.Context, job *Job) error {
+		if fail {
+			return errors.New("invalid token")
+		}
+		done <- job
+
+		return nil
+	})
+	assert.NoError(t, q.Start(1))
+
+	job := NewJob("publish")
+	assert.NoError(t, q.Enqueue(job))
+	assert.Eventually(t, func() bool {
+		dead, _ := q.DeadLetters()
+
+		return len(dead) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The token was fixed
+	fail = false
+	retried, err := q.Retry(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, job.ID, retried.ID)
+
+	select {
+	case processed := <-done:
+		assert.Equal(t, job.ID, processed.ID)
+		assert.Equal(t, 1, processed.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("dead letter wasn't retried")
+	}
+
+	dead, err := q.DeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, dead)
+}
+
+func TestQueueRetryScheduledJob(t *testing.T) {
+	done := make(chan *Job, 1)
+
+	q := New(NewMemoryStore(), func(ctx context.Context, job *Job) error {
+		if job.Attempts == 1 {
+			return errors.New("sonarqube unavailable")
+		}
+		done <- job
+
+		return nil
+	}, WithRetry(5, time.Hour, time.Hour))
+	assert.NoError(t, q.Start(1))
+
+	job := NewJob("publish")
+	assert.NoError(t, q.Enqueue(job))
+	assert.Eventually(t, func() bool {
+		return q.Scheduled() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := q.Retry(job.ID)
+	assert.NoError(t, err)
+
+	select {
+	case processed := <-done:
+		assert.Equal(t, 2, processed.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("scheduled job waited for the backoff")
+	}
+}
+
+func TestQueueCancelRunningJob(t *testing.T) {
+	started := make(chan struct{}, 1)
+
+	q := New(NewMemoryStore(), func(ctx context.Context, job *Job) error {
+		started <- struct{}{}
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, WithRetry(5, time.Millisecond, time.Millisecond), WithHistory(time.Hour))
+	assert.NoError(t, q.Start(1))
+
+	job := NewJob("publish")
+	assert.NoError(t, q.Enqueue(job))
+	<-started
+
+	_, err := q.Retry(job.ID)
+	assert.Equal(t, ErrJobActive, err)
+
+	assert.NoError(t, q.Cancel(job.ID))
+	assert.Eventually(t, func() bool {
+		found, err := q.Job(job.ID)
+
+		return err == nil && found.Status == STATUS_CANCELLED
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, ErrJobFinished, q.Cancel(job.ID))
+}
+
+func TestQueueCancelWaitingJob(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	done := make(chan *Job, 2)
+
+	q := New(NewMemoryStore(), func(ctx context.Context, job *Job) error {
+		if job.Kind == "busy" {
+			started <- struct{}{}
+			<-release
+		}
+		done <- job
+
+		return nil
+	}, WithHistory(time.Hour))
+	assert.NoError(t, q.Start(1))
+
+	assert.NoError(t, q.Enqueue(NewJob("busy")))
+	<-started
+
+	waiting := NewJob("publish")
+	waiting.Key = "myproject/3"
+	assert.NoError(t, q.Enqueue(waiting))
+	assert.NoError(t, q.Cancel(waiting.ID))
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		found, err := q.Job(waiting.ID)
+
+		return err == nil && found.Status == STATUS_CANCELLED
+	}, time.Second, 10*time.Millisecond)
+
+	// Only the busy job ran
+	assert.Len(t, done, 1)
+}
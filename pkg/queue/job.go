@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	STATUS_PENDING   = "pending"
+	STATUS_RUNNING   = "running"
+	STATUS_SCHEDULED = "scheduled"
+	STATUS_DONE      = "done"
+	STATUS_DEAD      = "dead"
+	STATUS_CANCELLED = "cancelled"
+)
+
+// Job is a serialisable unit of work, the kind tells the handler how to process it.
+// Jobs with the same key never run at the same time and a newer one of the same kind replaces the one still waiting
+type Job struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Key        string          `json:"key,omitempty"`
+	Project    string          `json:"project,omitempty"`
+	Branch     string          `json:"branch,omitempty"`
+	BranchType string          `json:"branchType,omitempty"`
+	Revision   string          `json:"revision,omitempty"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Status     string          `json:"status"`
+	Attempts   int             `json:"attempts"`
+	NextRunAt  time.Time       `json:"nextRunAt"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	FinishedAt time.Time       `json:"finishedAt"`
+	LastError  string          `json:"lastError,omitempty"`
+	// Result is what the handler reported about the last attempt
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// NewJob creates a job of the given kind ready to run
+func NewJob(kind string) *Job {
+	now := time.Now()
+
+	return &Job{
+		ID:        newID(),
+		Kind:      kind,
+		Status:    STATUS_PENDING,
+		NextRunAt: now,
+		CreatedAt: now,
+	}
+}
+
+// NewPayloadJob creates a job of the given kind carrying the given payload
+func NewPayloadJob(kind string, payload interface{}) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode job payload")
+	}
+
+	job := NewJob(kind)
+	job.Payload = data
+
+	return job, nil
+}
+
+// Decode reads the job payload into out
+func (j *Job) Decode(out interface{}) error {
+	err := json.Unmarshal(j.Payload, out)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode job payload")
+	}
+
+	return nil
+}
+
+// SetResult keeps what the handler did, like the issues published, to inspect the job later
+func (j *Job) SetResult(result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode job result")
+	}
+	j.Result = data
+
+	return nil
+}
+
+// replaceWith takes the work of the given newer job, keeping the identity of this one
+func (j *Job) replaceWith(newer *Job) {
+	j.Kind = newer.Kind
+	j.Project = newer.Project
+	j.Branch = newer.Branch
+	j.BranchType = newer.BranchType
+	j.Revision = newer.Revision
+	j.Payload = newer.Payload
+}
+
+// pendingKey groups the waiting jobs that do the same work, a newer one replaces the older
+func (j *Job) pendingKey() string {
+	return j.Kind + "/" + j.Key
+}
+
+// newID returns a random job ID
+func newID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		// Unique enough for a single process
+		return time.Now().Format("20060102150405.000000000")
+	}
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,682 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DEFAULT_BACKLOG is how many jobs can wait for a worker when WithBacklog isn't used
+const DEFAULT_BACKLOG = 100
+
+// CANCEL_GRACE is how long Shutdown waits for the cancelled jobs to return, so they can keep their
+// state in the store before it's closed
+const CANCEL_GRACE = 2 * time.Second
+
+// ErrQueueFull is returned when the backlog can't take more jobs
+var ErrQueueFull = errors.New("queue is full")
+
+// ErrQueueClosed is returned when the queue is shutting down
+var ErrQueueClosed = errors.New("queue is closed")
+
+// Handler processes a job, the errors are retried according to the queue options
+type Handler func(ctx context.Context, job *Job) error
+
+// Queue runs the jobs in a worker pool, the jobs are stored until they're finished
+// so the ones interrupted by a restart run again (at-least-once).
+// Failed jobs wait for their next attempt in a scheduler instead of holding a worker,
+// the ones that can't be retried end up in the dead letters
+type Queue struct {
+	store   Store
+	handler Handler
+	jobs    chan *Job
+
+	// pending has the jobs waiting to run by kind and key, locks the keys of the running jobs,
+	// running cancels the running jobs by ID and cancelled has the IDs to stop
+	mutex     sync.Mutex
+	pending   map[string]*Job
+	locks     map[string]*keyLock
+	running   map[string]context.CancelFunc
+	cancelled map[string]bool
+
+	retryIf     func(err error) bool
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	history     time.Duration
+
+	scheduledMutex sync.Mutex
+	scheduled      jobHeap
+	wake           chan struct{}
+
+	// ctx is given to the handlers and only cancelled when the running jobs don't finish in time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stop    chan struct{}
+	closed  bool
+	workers sync.WaitGroup
+
+	workerCount int
+	busy        int64
+	processed   uint64
+	failed      uint64
+	retried     uint64
+}
+
+type Option func(*Queue)
+
+// WithBacklog limits how many jobs can wait for a worker, when full new jobs are refused.
+// The retries and resumed jobs wait for a slot in the same backlog instead of being refused
+func WithBacklog(size int) Option {
+	return func(q *Queue) {
+		q.jobs = make(chan *Job, size)
+	}
+}
+
+// WithRetry retries the failed jobs up to the given attempts, waiting an exponential backoff between the given delays
+func WithRetry(maxAttempts int, baseDelay time.Duration, maxDelay time.Duration) Option {
+	return func(q *Queue) {
+		q.maxAttempts = maxAttempts
+		q.baseDelay = baseDelay
+		q.maxDelay = maxDelay
+	}
+}
+
+// WithRetryIf only retries the errors accepted by the given function
+func WithRetryIf(retryIf func(err error) bool) Option {
+	return func(q *Queue) {
+		q.retryIf = retryIf
+	}
+}
+
+// WithHistory keeps the finished jobs for the given time so they can be inspected, the dead letters are removed after the same time
+func WithHistory(retention time.Duration) Option {
+	return func(q *Queue) {
+		q.history = retention
+	}
+}
+
+// New creates a queue that processes the jobs with the given handler
+func New(store Store, handler Handler, opts ...Option) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &Queue{
+		store:       store,
+		handler:     handler,
+		jobs:        make(chan *Job, DEFAULT_BACKLOG),
+		retryIf:     func(err error) bool { return true },
+		maxAttempts: 1,
+		pending:     make(map[string]*Job),
+		locks:       make(map[string]*keyLock),
+		running:     make(map[string]context.CancelFunc),
+		cancelled:   make(map[string]bool),
+		wake:        make(chan struct{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+		stop:        make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q
+}
+
+// Start starts the workers and resumes the jobs left in the store
+func (q *Queue) Start(workers int) error {
+	pending, err := q.store.List()
+	if err != nil {
+		return errors.Wrap(err, "failed to load pending jobs")
+	}
+
+	q.workerCount = workers
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	go q.schedule()
+
+	if len(pending) > 0 {
+		logrus.Infoln("Resuming", len(pending), "pending jobs")
+
+		// Already accepted, the scheduler hands them to the workers when the backlog has room
+		for _, job := range pending {
+			q.resume(job)
+		}
+	}
+
+	return nil
+}
+
+// Enqueue stores the job and hands it to a worker without blocking, ErrQueueFull is returned when the backlog is full.
+// When a job with the same key is still waiting, it takes the work of the given job instead
+func (q *Queue) Enqueue(job *Job) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	if pending, ok := q.pending[job.pendingKey()]; ok && job.Key != "" {
+		logrus.WithField("job", pending.ID).Debugln("Replacing pending", pending.Kind, "job of", job.Key)
+		pending.replaceWith(job)
+		// The caller follows the job it was merged into
+		job.ID = pending.ID
+
+		// The newer work doesn't wait for the retry of the old one
+		if q.unschedule(pending) {
+			pending.Status = STATUS_PENDING
+			pending.Attempts = 0
+			pending.LastError = ""
+			q.retryAt(pending, time.Now())
+		}
+
+		return q.store.Put(pending)
+	}
+
+	// The workers and newer jobs change the queued one, the caller may still be reading the given job
+	queued := *job
+	if err := q.store.Put(&queued); err != nil {
+		return err
+	}
+
+	select {
+	case q.jobs <- &queued:
+		if queued.Key != "" {
+			q.pending[queued.pendingKey()] = &queued
+		}
+
+		return nil
+	default:
+		if err := q.store.Delete(job.ID); err != nil {
+			logrus.WithError(err).Warnln("Failed to remove refused job", job.ID)
+		}
+
+		return ErrQueueFull
+	}
+}
+
+// resume schedules a job left in the store, merging the ones with the same key
+func (q *Queue) resume(job *Job) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if pending, ok := q.pending[job.pendingKey()]; ok && job.Key != "" {
+		// Stored jobs come oldest first
+		pending.replaceWith(job)
+		if err := q.store.Put(pending); err != nil {
+			logrus.WithError(err).Warnln("Failed to store the resumed job", pending.ID)
+		}
+		if err := q.store.Delete(job.ID); err != nil {
+			logrus.WithError(err).Warnln("Failed to remove replaced job", job.ID)
+		}
+
+		return
+	}
+
+	job.Status = STATUS_PENDING
+	if job.NextRunAt.After(time.Now()) {
+		job.Status = STATUS_SCHEDULED
+	}
+	if job.Key != "" {
+		q.pending[job.pendingKey()] = job
+	}
+	q.retryAt(job, job.NextRunAt)
+}
+
+// Depth returns how many jobs are waiting for a worker
+func (q *Queue) Depth() int {
+	return len(q.jobs)
+}
+
+// Capacity returns how many jobs can wait for a worker
+func (q *Queue) Capacity() int {
+	return cap(q.jobs)
+}
+
+// Scheduled returns how many jobs are waiting for their next attempt
+func (q *Queue) Scheduled() int {
+	q.scheduledMutex.Lock()
+	defer q.scheduledMutex.Unlock()
+
+	return len(q.scheduled)
+}
+
+// Closed checks if the queue is shutting down
+func (q *Queue) Closed() bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return q.closed
+}
+
+// Workers returns how many workers were started
+func (q *Queue) Workers() int {
+	return q.workerCount
+}
+
+// Busy returns how many workers are processing a job
+func (q *Queue) Busy() int {
+	return int(atomic.LoadInt64(&q.busy))
+}
+
+// Processed returns how many jobs finished successfully
+func (q *Queue) Processed() uint64 {
+	return atomic.LoadUint64(&q.processed)
+}
+
+// Failed returns how many jobs were moved to the dead letters
+func (q *Queue) Failed() uint64 {
+	return atomic.LoadUint64(&q.failed)
+}
+
+// Retried returns how many failed attempts were scheduled to run again
+func (q *Queue) Retried() uint64 {
+	return atomic.LoadUint64(&q.retried)
+}
+
+// DeadLetters returns the jobs that failed permanently
+func (q *Queue) DeadLetters() ([]*Job, error) {
+	return q.store.ListDead()
+}
+
+// Shutdown stops taking jobs and cancels the scheduled retries, then waits for the running jobs until
+// the given context is done, when their context is cancelled. The unfinished jobs are kept in the store
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+
+		return nil
+	}
+	q.closed = true
+	q.mutex.Unlock()
+
+	close(q.stop)
+
+	stopped := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "running jobs didn't finish in time")
+	}
+	q.cancel()
+
+	// The handlers should return soon after being cancelled
+	if err != nil {
+		select {
+		case <-stopped:
+		case <-time.After(CANCEL_GRACE):
+			logrus.Warnln("Running jobs didn't return after being cancelled, their state may not be kept")
+		}
+	}
+
+	unfinished, listErr := q.store.List()
+	if listErr != nil {
+		logrus.WithError(listErr).Warnln("Failed to list the unfinished jobs")
+	}
+	for _, job := range unfinished {
+		logrus.WithField("job", job.ID).WithField("attempts", job.Attempts).Infoln("Keeping unfinished", job.Kind, "job of", job.Project, job.Branch, "for the next start")
+	}
+
+	return err
+}
+
+// stopping checks if the queue is shutting down
+func (q *Queue) stopping() bool {
+	select {
+	case <-q.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// work processes the jobs until the queue is shut down, the jobs left in the backlog stay in the store
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case job := <-q.jobs:
+			if q.stopping() {
+				return
+			}
+
+			ctx, ok := q.take(job)
+			if !ok {
+				continue
+			}
+
+			atomic.AddInt64(&q.busy, 1)
+			q.process(ctx, job)
+			atomic.AddInt64(&q.busy, -1)
+		}
+	}
+}
+
+// take stops the job from being replaced by newer ones as it's about to run, returning the context
+// to cancel it. False is returned when the job was cancelled while waiting
+func (q *Queue) take(job *Job) (context.Context, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.pending[job.pendingKey()] == job {
+		delete(q.pending, job.pendingKey())
+	}
+
+	if q.cancelled[job.ID] {
+		delete(q.cancelled, job.ID)
+		q.finish(job, STATUS_CANCELLED)
+
+		return nil, false
+	}
+
+	ctx, cancel := context.WithCancel(q.ctx)
+	q.running[job.ID] = cancel
+
+	return ctx, true
+}
+
+// release forgets the running job, true is returned when it was cancelled
+func (q *Queue) release(job *Job) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if cancel, ok := q.running[job.ID]; ok {
+		cancel()
+		delete(q.running, job.ID)
+	}
+
+	cancelled := q.cancelled[job.ID]
+	delete(q.cancelled, job.ID)
+
+	return cancelled
+}
+
+// process runs an attempt of the job, scheduling the next one when it fails
+func (q *Queue) process(ctx context.Context, job *Job) {
+	job.Status = STATUS_RUNNING
+	job.Attempts++
+	if err := q.store.Put(job); err != nil {
+		logrus.WithError(err).Warnln("Failed to store the attempt of job", job.ID)
+	}
+
+	unlock := q.lockKey(job.Key)
+	if q.stopping() {
+		// Waited for another job of the key while shutting down
+		unlock()
+		q.release(job)
+		q.interrupted(job)
+
+		return
+	}
+	err := q.handler(ctx, job)
+	unlock()
+
+	if q.release(job) {
+		logrus.WithField("job", job.ID).Infoln("Cancelled", job.Kind, "job")
+		q.finish(job, STATUS_CANCELLED)
+
+		return
+	}
+
+	if err == nil {
+		atomic.AddUint64(&q.processed, 1)
+		q.finish(job, STATUS_DONE)
+
+		return
+	}
+	job.LastError = err.Error()
+
+	// Interrupted by the shutdown, it runs again on the next start
+	if q.ctx.Err() != nil {
+		logrus.WithError(err).WithField("job", job.ID).Warnln("Interrupted", job.Kind, "job")
+		q.interrupted(job)
+
+		return
+	}
+
+	if !q.retryIf(err) || job.Attempts >= q.maxAttempts {
+		logrus.WithError(err).WithField("job", job.ID).WithField("attempts", job.Attempts).Errorln("Failed to process", job.Kind, "job")
+		q.bury(job)
+
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	// A newer job with the same key is already waiting to do the same work
+	if _, ok := q.pending[job.pendingKey()]; ok && job.Key != "" {
+		logrus.WithError(err).WithField("job", job.ID).Warnln("Failed to process", job.Kind, "job, replaced by a newer one")
+		if err := q.store.Delete(job.ID); err != nil {
+			logrus.WithError(err).Warnln("Failed to remove replaced job", job.ID)
+		}
+
+		return
+	}
+
+	atomic.AddUint64(&q.retried, 1)
+	delay := q.backoff(job.Attempts)
+	logrus.WithError(err).WithField("job", job.ID).Warnln("Failed to process", job.Kind, "job, retrying in", delay)
+
+	job.Status = STATUS_SCHEDULED
+	job.NextRunAt = time.Now().Add(delay)
+	if err := q.store.Put(job); err != nil {
+		logrus.WithError(err).Warnln("Failed to store the next attempt of job", job.ID)
+	}
+	job.Status = STATUS_PENDING
+	if job.NextRunAt.After(time.Now()) {
+		job.Status = STATUS_SCHEDULED
+	}
+	if job.Key != "" {
+		q.pending[job.pendingKey()] = job
+	}
+	q.retryAt(job, job.NextRunAt)
+}
+
+// interrupted keeps the job to run again on the next start
+func (q *Queue) interrupted(job *Job) {
+	job.Status = STATUS_PENDING
+	if err := q.store.Put(job); err != nil {
+		logrus.WithError(err).Warnln("Failed to store the interrupted job", job.ID)
+	}
+}
+
+// finish removes the job from the store, keeping it in the history when enabled
+func (q *Queue) finish(job *Job, status string) {
+	job.Status = status
+	job.FinishedAt = time.Now()
+
+	if q.history > 0 {
+		if err := q.store.PutFinished(job); err != nil {
+			logrus.WithError(err).Warnln("Failed to keep the history of job", job.ID)
+		}
+	}
+	q.prune()
+
+	if err := q.store.Delete(job.ID); err != nil {
+		logrus.WithError(err).Warnln("Failed to remove finished job", job.ID)
+	}
+}
+
+// bury moves the job into the dead letters
+func (q *Queue) bury(job *Job) {
+	atomic.AddUint64(&q.failed, 1)
+	job.Status = STATUS_DEAD
+	job.FinishedAt = time.Now()
+	if err := q.store.PutDead(job); err != nil {
+		logrus.WithError(err).Warnln("Failed to store dead letter", job.ID)
+	}
+	if err := q.store.Delete(job.ID); err != nil {
+		logrus.WithError(err).Warnln("Failed to remove dead job", job.ID)
+	}
+	q.prune()
+}
+
+// prune removes the finished jobs and dead letters older than the history retention, when enabled
+func (q *Queue) prune() {
+	if q.history <= 0 {
+		return
+	}
+
+	before := time.Now().Add(-q.history)
+	if err := q.store.PruneFinished(before); err != nil {
+		logrus.WithError(err).Warnln("Failed to prune the finished jobs")
+	}
+	if err := q.store.PruneDead(before); err != nil {
+		logrus.WithError(err).Warnln("Failed to prune the dead letters")
+	}
+}
+
+// backoff returns the delay before the attempt after the given one
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.baseDelay
+	for i := 1; i < attempts && delay < q.maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > q.maxDelay {
+		return q.maxDelay
+	}
+
+	return delay
+}
+
+// retryAt schedules the job to run at the given time
+func (q *Queue) retryAt(job *Job, at time.Time) {
+	job.NextRunAt = at
+
+	q.scheduledMutex.Lock()
+	heap.Push(&q.scheduled, job)
+	q.scheduledMutex.Unlock()
+
+	// Let the scheduler know there may be an earlier job
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// unschedule removes the job from the scheduler, false is returned when it isn't scheduled
+func (q *Queue) unschedule(job *Job) bool {
+	return q.unscheduleID(job.ID) != nil
+}
+
+// unscheduleID removes the job with the given ID from the scheduler, nil is returned when it isn't scheduled
+func (q *Queue) unscheduleID(id string) *Job {
+	q.scheduledMutex.Lock()
+	defer q.scheduledMutex.Unlock()
+
+	for i, scheduled := range q.scheduled {
+		if scheduled.ID == id {
+			heap.Remove(&q.scheduled, i)
+
+			return scheduled
+		}
+	}
+
+	return nil
+}
+
+// schedule hands the scheduled jobs to the workers when their time comes, until the queue is shut down
+func (q *Queue) schedule() {
+	for {
+		wait := time.Hour
+
+		q.scheduledMutex.Lock()
+		if len(q.scheduled) > 0 {
+			next := q.scheduled[0]
+			if !next.NextRunAt.After(time.Now()) {
+				heap.Pop(&q.scheduled)
+				q.scheduledMutex.Unlock()
+
+				select {
+				case q.jobs <- next:
+				case <-q.stop:
+					return
+				}
+
+				continue
+			}
+			wait = time.Until(next.NextRunAt)
+		}
+		q.scheduledMutex.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-q.wake:
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// keyLock serializes the jobs of a key, it's removed when nobody holds or waits for it
+type keyLock struct {
+	mutex sync.Mutex
+	refs  int
+}
+
+// lockKey waits until no other job with the given key is running, the returned function releases it
+func (q *Queue) lockKey(key string) func() {
+	if key == "" {
+		return func() {}
+	}
+
+	q.mutex.Lock()
+	lock, ok := q.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		q.locks[key] = lock
+	}
+	lock.refs++
+	q.mutex.Unlock()
+
+	lock.mutex.Lock()
+
+	return func() {
+		lock.mutex.Unlock()
+
+		q.mutex.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(q.locks, key)
+		}
+		q.mutex.Unlock()
+	}
+}
+
+// jobHeap sorts the jobs by their next attempt
+type jobHeap []*Job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, j int) bool { return h[i].NextRunAt.Before(h[j].NextRunAt) }
+func (h jobHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *jobHeap) Push(x interface{}) {
+	*h = append(*h, x.(*Job))
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	job := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return job
+}
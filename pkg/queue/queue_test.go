@@ -0,0 +1,465 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueProcessesAndRemovesJobs(t *testing.T) {
+	store := NewMemoryStore()
+	done := make(chan *Job, 1)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		done <- job
+
+		return nil
+	})
+	assert.NoError(t, q.Start(1))
+
+	job := NewJob("publish")
+	assert.NoError(t, q.Enqueue(job))
+
+	select {
+	case processed := <-done:
+		assert.Equal(t, job.ID, processed.ID)
+		assert.Equal(t, 1, processed.Attempts)
+		// The queue works on its own copy
+		assert.Equal(t, 0, job.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("job wasn't processed")
+	}
+
+	assert.Eventually(t, func() bool {
+		jobs, _ := store.List()
+
+		return len(jobs) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestQueueResumesStoredJobs(t *testing.T) {
+	store := NewMemoryStore()
+
+	// Left by a previous run
+	pending := NewJob("publish")
+	pending.Attempts = 2
+	assert.NoError(t, store.Put(pending))
+
+	done := make(chan *Job, 1)
+	q := New(store, func(ctx context.Context, job *Job) error {
+		done <- job
+
+		return nil
+	})
+	assert.NoError(t, q.Start(1))
+
+	select {
+	case processed := <-done:
+		assert.Equal(t, pending.ID, processed.ID)
+		assert.Equal(t, 3, processed.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("pending job wasn't resumed")
+	}
+}
+
+func TestQueueRetriesFailedJobs(t *testing.T) {
+	store := NewMemoryStore()
+	done := make(chan *Job, 1)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		if job.Attempts < 3 {
+			return errors.New("sonarqube unavailable")
+		}
+		done <- job
+
+		return nil
+	}, WithRetry(3, time.Millisecond, 10*time.Millisecond))
+	assert.NoError(t, q.Start(1))
+
+	assert.NoError(t, q.Enqueue(NewJob("publish")))
+
+	select {
+	case processed := <-done:
+		assert.Equal(t, 3, processed.Attempts)
+		assert.Equal(t, "sonarqube unavailable", processed.LastError)
+	case <-time.After(time.Second):
+		t.Fatal("job wasn't retried")
+	}
+}
+
+func TestQueueRefusesJobsWhenFull(t *testing.T) {
+	store := NewMemoryStore()
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		started <- struct{}{}
+		<-release
+
+		return nil
+	}, WithBacklog(1))
+	assert.NoError(t, q.Start(1))
+	defer close(release)
+
+	// The worker is busy with the first job and the second one fills the backlog
+	assert.NoError(t, q.Enqueue(NewJob("publish")))
+	<-started
+	assert.NoError(t, q.Enqueue(NewJob("publish")))
+	assert.Equal(t, 1, q.Depth())
+	assert.Equal(t, 1, q.Capacity())
+
+	refused := NewJob("publish")
+	assert.Equal(t, ErrQueueFull, q.Enqueue(refused))
+
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+	for _, job := range jobs {
+		assert.NotEqual(t, refused.ID, job.ID)
+	}
+}
+
+func TestQueueBuriesJobsWhenAttemptsAreExhausted(t *testing.T) {
+	store := NewMemoryStore()
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		return errors.New("sonarqube unavailable")
+	}, WithRetry(2, time.Millisecond, time.Millisecond))
+	assert.NoError(t, q.Start(1))
+
+	job := NewJob("publish")
+	assert.NoError(t, q.Enqueue(job))
+
+	assert.Eventually(t, func() bool {
+		dead, _ := q.DeadLetters()
+
+		return len(dead) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	dead, err := q.DeadLetters()
+	assert.NoError(t, err)
+	assert.Equal(t, job.ID, dead[0].ID)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, "sonarqube unavailable", dead[0].LastError)
+
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 0)
+}
+
+func TestQueueBuriesJobsThatCantBeRetried(t *testing.T) {
+	store := NewMemoryStore()
+	invalidToken := errors.New("invalid token")
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		return invalidToken
+	}, WithRetry(5, time.Millisecond, time.Millisecond), WithRetryIf(func(err error) bool {
+		return err != invalidToken
+	}))
+	assert.NoError(t, q.Start(1))
+
+	assert.NoError(t, q.Enqueue(NewJob("publish")))
+
+	assert.Eventually(t, func() bool {
+		dead, _ := q.DeadLetters()
+
+		return len(dead) == 1 && dead[0].Attempts == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestQueueDoesntBlockWorkersDuringBackoff(t *testing.T) {
+	store := NewMemoryStore()
+	done := make(chan *Job, 1)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		if job.Kind == "failing" {
+			return errors.New("sonarqube unavailable")
+		}
+		done <- job
+
+		return nil
+	}, WithRetry(5, time.Hour, time.Hour))
+	assert.NoError(t, q.Start(1))
+
+	// The only worker is free to process the next job while the failed one waits
+	failing := NewJob("failing")
+	assert.NoError(t, q.Enqueue(failing))
+	assert.Eventually(t, func() bool {
+		return q.Scheduled() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, q.Enqueue(NewJob("publish")))
+	select {
+	case processed := <-done:
+		assert.Equal(t, "publish", processed.Kind)
+	case <-time.After(time.Second):
+		t.Fatal("worker was blocked by the failed job")
+	}
+
+	// The failed job is kept with its next attempt
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, failing.ID, jobs[0].ID)
+	assert.True(t, jobs[0].NextRunAt.After(time.Now().Add(59*time.Minute)))
+}
+
+func TestQueueBackoff(t *testing.T) {
+	q := New(NewMemoryStore(), nil, WithRetry(10, time.Minute, 10*time.Minute))
+
+	assert.Equal(t, time.Minute, q.backoff(1))
+	assert.Equal(t, 2*time.Minute, q.backoff(2))
+	assert.Equal(t, 8*time.Minute, q.backoff(4))
+	assert.Equal(t, 10*time.Minute, q.backoff(5))
+	assert.Equal(t, 10*time.Minute, q.backoff(9))
+}
+
+func TestQueueReplacesPendingJobsWithTheSameKey(t *testing.T) {
+	store := NewMemoryStore()
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	done := make(chan *Job, 3)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		if job.Key == "busy" {
+			started <- struct{}{}
+			<-release
+
+			return nil
+		}
+		done <- job
+
+		return nil
+	})
+	assert.NoError(t, q.Start(1))
+
+	// Hold the only worker so the next jobs wait
+	busy := NewJob("publish")
+	busy.Key = "busy"
+	assert.NoError(t, q.Enqueue(busy))
+	<-started
+
+	first := NewJob("publish")
+	first.Key = "myproject/3"
+	first.Revision = "abc123"
+	assert.NoError(t, q.Enqueue(first))
+
+	second := NewJob("publish")
+	second.Key = "myproject/3"
+	second.Revision = "def456"
+	assert.NoError(t, q.Enqueue(second))
+	assert.Equal(t, 1, q.Depth())
+	assert.Equal(t, first.ID, second.ID)
+
+	close(release)
+
+	select {
+	case processed := <-done:
+		assert.Equal(t, first.ID, processed.ID)
+		assert.Equal(t, "def456", processed.Revision)
+	case <-time.After(time.Second):
+		t.Fatal("job wasn't processed")
+	}
+
+	assert.Eventually(t, func() bool {
+		jobs, _ := store.List()
+
+		return len(jobs) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Len(t, done, 0)
+}
+
+func TestQueueRunsNewerJobInsteadOfRetry(t *testing.T) {
+	store := NewMemoryStore()
+	done := make(chan *Job, 1)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		if job.Revision == "abc123" {
+			return errors.New("sonarqube unavailable")
+		}
+		done <- job
+
+		return nil
+	}, WithRetry(5, time.Hour, time.Hour))
+	assert.NoError(t, q.Start(1))
+
+	failing := NewJob("publish")
+	failing.Key = "myproject/3"
+	failing.Revision = "abc123"
+	assert.NoError(t, q.Enqueue(failing))
+	assert.Eventually(t, func() bool {
+		return q.Scheduled() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	newer := NewJob("publish")
+	newer.Key = "myproject/3"
+	newer.Revision = "def456"
+	assert.NoError(t, q.Enqueue(newer))
+
+	select {
+	case processed := <-done:
+		assert.Equal(t, failing.ID, processed.ID)
+		assert.Equal(t, "def456", processed.Revision)
+		assert.Equal(t, 1, processed.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("newer job waited for the retry")
+	}
+	assert.Equal(t, 0, q.Scheduled())
+}
+
+func TestQueueRunsOneJobPerKey(t *testing.T) {
+	var running, maxRunning int32
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	q := New(NewMemoryStore(), func(ctx context.Context, job *Job) error {
+		defer wg.Done()
+
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+
+		return nil
+	})
+	assert.NoError(t, q.Start(4))
+
+	// Each job is taken by a worker before the next one is enqueued, so none are replaced
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		job := NewJob("publish")
+		job.Key = "myproject/3"
+		assert.NoError(t, q.Enqueue(job))
+		assert.Eventually(t, func() bool {
+			return q.Depth() == 0
+		}, time.Second, time.Millisecond)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), maxRunning)
+}
+
+func TestQueueDoesntReplaceJobsOfAnotherKind(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	done := make(chan *Job, 2)
+
+	q := New(NewMemoryStore(), func(ctx context.Context, job *Job) error {
+		if job.Key == "busy" {
+			started <- struct{}{}
+			<-release
+
+			return nil
+		}
+		done <- job
+
+		return nil
+	})
+	assert.NoError(t, q.Start(1))
+
+	// Hold the only worker so the next jobs wait
+	busy := NewJob("publish")
+	busy.Key = "busy"
+	assert.NoError(t, q.Enqueue(busy))
+	<-started
+
+	publish := NewJob("publish")
+	publish.Key = "myproject/3"
+	assert.NoError(t, q.Enqueue(publish))
+
+	command := NewJob("command")
+	command.Key = "myproject/3"
+	assert.NoError(t, q.Enqueue(command))
+	assert.NotEqual(t, publish.ID, command.ID)
+	assert.Equal(t, 2, q.Depth())
+
+	close(release)
+
+	for _, kind := range []string{"publish", "command"} {
+		select {
+		case processed := <-done:
+			assert.Equal(t, kind, processed.Kind)
+		case <-time.After(time.Second):
+			t.Fatal("job wasn't processed")
+		}
+	}
+}
+
+func TestQueueShutdownWaitsForRunningJobs(t *testing.T) {
+	store := NewMemoryStore()
+	started := make(chan struct{}, 1)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		started <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+
+		return ctx.Err()
+	})
+	assert.NoError(t, q.Start(1))
+
+	assert.NoError(t, q.Enqueue(NewJob("publish")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, q.Shutdown(ctx))
+
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	assert.Equal(t, ErrQueueClosed, q.Enqueue(NewJob("publish")))
+}
+
+func TestQueueShutdownKeepsUnfinishedJobs(t *testing.T) {
+	store := NewMemoryStore()
+	started := make(chan struct{}, 1)
+
+	q := New(store, func(ctx context.Context, job *Job) error {
+		if job.Kind == "failing" {
+			return errors.New("sonarqube unavailable")
+		}
+		started <- struct{}{}
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, WithRetry(5, time.Hour, time.Hour))
+	assert.NoError(t, q.Start(1))
+
+	// A retry waiting in the scheduler and a job that doesn't finish in time
+	failing := NewJob("failing")
+	assert.NoError(t, q.Enqueue(failing))
+	assert.Eventually(t, func() bool {
+		return q.Scheduled() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	running := NewJob("publish")
+	assert.NoError(t, q.Enqueue(running))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Error(t, q.Shutdown(ctx))
+
+	// The cancelled job is kept before returning so the store can be closed
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, context.Canceled.Error(), jobs[1].LastError)
+
+	dead, err := q.DeadLetters()
+	assert.NoError(t, err)
+	assert.Empty(t, dead)
+}
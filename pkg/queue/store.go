@@ -0,0 +1,425 @@
+package queue
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var jobsBucket = []byte("jobs")
+var deadBucket = []byte("dead")
+var finishedBucket = []byte("finished")
+
+// Store keeps the jobs that weren't finished yet, the dead letters and the history of finished jobs
+type Store interface {
+	Put(job *Job) error
+	Delete(id string) error
+	// Get returns the stored job with the given ID, nil when missing
+	Get(id string) (*Job, error)
+	// List returns the stored jobs, oldest first
+	List() ([]*Job, error)
+	// PutDead keeps a job that failed permanently
+	PutDead(job *Job) error
+	// GetDead returns the dead letter with the given ID, nil when missing
+	GetDead(id string) (*Job, error)
+	// ListDead returns the jobs that failed permanently, oldest first
+	ListDead() ([]*Job, error)
+	DeleteDead(id string) error
+	// PutFinished keeps a finished job in the history
+	PutFinished(job *Job) error
+	// GetFinished returns the finished job with the given ID, nil when missing
+	GetFinished(id string) (*Job, error)
+	// ListFinished returns the history of finished jobs, oldest first
+	ListFinished() ([]*Job, error)
+	// PruneFinished removes the jobs finished before the given time from the history
+	PruneFinished(before time.Time) error
+	// PruneDead removes the jobs that failed permanently before the given time
+	PruneDead(before time.Time) error
+	Close() error
+}
+
+// MemoryStore keeps the jobs in memory, they are lost on restart
+type MemoryStore struct {
+	mutex    sync.Mutex
+	jobs     map[string]Job
+	dead     map[string]Job
+	finished map[string]Job
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[string]Job), dead: make(map[string]Job), finished: make(map[string]Job)}
+}
+
+// Put stores the job, replacing the one with the same ID
+func (m *MemoryStore) Put(job *Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.jobs[job.ID] = *job
+
+	return nil
+}
+
+// Delete removes the job with the given ID
+func (m *MemoryStore) Delete(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.jobs, id)
+
+	return nil
+}
+
+// Get returns the stored job with the given ID, nil when missing
+func (m *MemoryStore) Get(id string) (*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return getJob(m.jobs, id), nil
+}
+
+// List returns the stored jobs, oldest first
+func (m *MemoryStore) List() ([]*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return listJobs(m.jobs), nil
+}
+
+// PutDead keeps a job that failed permanently
+func (m *MemoryStore) PutDead(job *Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.dead[job.ID] = *job
+
+	return nil
+}
+
+// GetDead returns the dead letter with the given ID, nil when missing
+func (m *MemoryStore) GetDead(id string) (*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return getJob(m.dead, id), nil
+}
+
+// ListDead returns the jobs that failed permanently, oldest first
+func (m *MemoryStore) ListDead() ([]*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return listJobs(m.dead), nil
+}
+
+// DeleteDead removes the dead letter with the given ID
+func (m *MemoryStore) DeleteDead(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.dead, id)
+
+	return nil
+}
+
+// PutFinished keeps a finished job in the history
+func (m *MemoryStore) PutFinished(job *Job) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.finished[job.ID] = *job
+
+	return nil
+}
+
+// GetFinished returns the finished job with the given ID, nil when missing
+func (m *MemoryStore) GetFinished(id string) (*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return getJob(m.finished, id), nil
+}
+
+// ListFinished returns the history of finished jobs, oldest first
+func (m *MemoryStore) ListFinished() ([]*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return listJobs(m.finished), nil
+}
+
+// PruneFinished removes the jobs finished before the given time from the history
+func (m *MemoryStore) PruneFinished(before time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	pruneJobs(m.finished, before)
+
+	return nil
+}
+
+// PruneDead removes the jobs that failed permanently before the given time
+func (m *MemoryStore) PruneDead(before time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	pruneJobs(m.dead, before)
+
+	return nil
+}
+
+// Close releases the store
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// BoltStore keeps the jobs in a BoltDB file so they survive restarts
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the BoltDB file in the given path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open queue database")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{jobsBucket, deadBucket, finishedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+
+		return nil, errors.Wrap(err, "failed to create queue bucket")
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Put stores the job, replacing the one with the same ID
+func (b *BoltStore) Put(job *Job) error {
+	return b.put(jobsBucket, job)
+}
+
+// Delete removes the job with the given ID
+func (b *BoltStore) Delete(id string) error {
+	return b.delete(jobsBucket, id)
+}
+
+// Get returns the stored job with the given ID, nil when missing
+func (b *BoltStore) Get(id string) (*Job, error) {
+	return b.get(jobsBucket, id)
+}
+
+// List returns the stored jobs, oldest first
+func (b *BoltStore) List() ([]*Job, error) {
+	return b.list(jobsBucket)
+}
+
+// PutDead keeps a job that failed permanently
+func (b *BoltStore) PutDead(job *Job) error {
+	return b.put(deadBucket, job)
+}
+
+// GetDead returns the dead letter with the given ID, nil when missing
+func (b *BoltStore) GetDead(id string) (*Job, error) {
+	return b.get(deadBucket, id)
+}
+
+// ListDead returns the jobs that failed permanently, oldest first
+func (b *BoltStore) ListDead() ([]*Job, error) {
+	return b.list(deadBucket)
+}
+
+// DeleteDead removes the dead letter with the given ID
+func (b *BoltStore) DeleteDead(id string) error {
+	return b.delete(deadBucket, id)
+}
+
+// PutFinished keeps a finished job in the history
+func (b *BoltStore) PutFinished(job *Job) error {
+	return b.put(finishedBucket, job)
+}
+
+// GetFinished returns the finished job with the given ID, nil when missing
+func (b *BoltStore) GetFinished(id string) (*Job, error) {
+	return b.get(finishedBucket, id)
+}
+
+// ListFinished returns the history of finished jobs, oldest first
+func (b *BoltStore) ListFinished() ([]*Job, error) {
+	return b.list(finishedBucket)
+}
+
+// PruneFinished removes the jobs finished before the given time from the history
+func (b *BoltStore) PruneFinished(before time.Time) error {
+	return b.prune(finishedBucket, before)
+}
+
+// PruneDead removes the jobs that failed permanently before the given time
+func (b *BoltStore) PruneDead(before time.Time) error {
+	return b.prune(deadBucket, before)
+}
+
+// prune removes the jobs finished before the given time from the given bucket
+func (b *BoltStore) prune(bucketName []byte, before time.Time) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+
+		// Keys can't be deleted while iterating
+		var expired [][]byte
+		err := bucket.ForEach(func(id, data []byte) error {
+			var job Job
+			if err := json.Unmarshal(data, &job); err != nil {
+				return err
+			}
+			if job.FinishedAt.Before(before) {
+				expired = append(expired, id)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range expired {
+			if err := bucket.Delete(id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to prune jobs")
+	}
+
+	return nil
+}
+
+// delete removes the job with the given ID from the given bucket
+func (b *BoltStore) delete(bucket []byte, id string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(id))
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to delete job")
+	}
+
+	return nil
+}
+
+// put stores the job in the given bucket
+func (b *BoltStore) put(bucket []byte, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode job")
+	}
+
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(job.ID), data)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to store job")
+	}
+
+	return nil
+}
+
+// get returns the job with the given ID from the given bucket, nil when missing
+func (b *BoltStore) get(bucket []byte, id string) (*Job, error) {
+	var job *Job
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+
+		job = &Job{}
+
+		return json.Unmarshal(data, job)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get job")
+	}
+
+	return job, nil
+}
+
+// list returns the jobs of the given bucket, oldest first
+func (b *BoltStore) list(bucket []byte) ([]*Job, error) {
+	jobs := make([]*Job, 0)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, data []byte) error {
+			var job Job
+			if err := json.Unmarshal(data, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, &job)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list jobs")
+	}
+	sortByCreation(jobs)
+
+	return jobs, nil
+}
+
+// Close releases the store
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// getJob copies the job with the given ID, nil when missing
+func getJob(stored map[string]Job, id string) *Job {
+	job, ok := stored[id]
+	if !ok {
+		return nil
+	}
+
+	return &job
+}
+
+// listJobs copies the given jobs, oldest first
+func listJobs(stored map[string]Job) []*Job {
+	jobs := make([]*Job, 0, len(stored))
+	for _, job := range stored {
+		job := job
+		jobs = append(jobs, &job)
+	}
+	sortByCreation(jobs)
+
+	return jobs
+}
+
+// pruneJobs removes the jobs finished before the given time
+func pruneJobs(stored map[string]Job, before time.Time) {
+	for id, job := range stored {
+		if job.FinishedAt.Before(before) {
+			delete(stored, id)
+		}
+	}
+}
+
+// sortByCreation sorts the jobs from the oldest to the newest
+func sortByCreation(jobs []*Job) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+}
@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+
+	store, err := NewBoltStore(path)
+	assert.NoError(t, err)
+
+	first := NewJob("publish")
+	first.Project = "myproject"
+	first.Branch = "3"
+	first.CreatedAt = time.Now().Add(-time.Minute)
+	second, err := NewPayloadJob("feedback", map[string]string{"issueKey": "AX2GHjk1-Wk2ioy15Nrv"})
+	assert.NoError(t, err)
+	third := NewJob("publish")
+
+	assert.NoError(t, store.Put(second))
+	assert.NoError(t, store.Put(first))
+	assert.NoError(t, store.Put(third))
+	assert.NoError(t, store.Delete(third.ID))
+	assert.NoError(t, store.Close())
+
+	// Reopen
+	store, err = NewBoltStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, first.ID, jobs[0].ID)
+	assert.Equal(t, "myproject", jobs[0].Project)
+	assert.Equal(t, second.ID, jobs[1].ID)
+
+	var payload map[string]string
+	assert.NoError(t, jobs[1].Decode(&payload))
+	assert.Equal(t, "AX2GHjk1-Wk2ioy15Nrv", payload["issueKey"])
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+
+	job := NewJob("publish")
+	assert.NoError(t, store.Put(job))
+
+	// Stored jobs are copies
+	job.Attempts = 3
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 0, jobs[0].Attempts)
+
+	stored, err := store.Get(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, job.ID, stored.ID)
+	assert.Equal(t, 0, stored.Attempts)
+
+	assert.NoError(t, store.Delete(job.ID))
+	jobs, err = store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestBoltStoreKeepsDeadLetters(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "queue.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	job := NewJob("publish")
+	job.Attempts = 5
+	job.LastError = "sonarqube unavailable"
+	job.FinishedAt = time.Now()
+	assert.NoError(t, store.Put(job))
+	assert.NoError(t, store.PutDead(job))
+	assert.NoError(t, store.Delete(job.ID))
+
+	jobs, err := store.List()
+	assert.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	dead, err := store.ListDead()
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, job.ID, dead[0].ID)
+	assert.Equal(t, "sonarqube unavailable", dead[0].LastError)
+
+	stored, err := store.GetDead(job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "sonarqube unavailable", stored.LastError)
+	stored, err = store.Get(job.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+
+	// Expired
+	assert.NoError(t, store.PruneDead(time.Now().Add(-time.Hour)))
+	dead, err = store.ListDead()
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.NoError(t, store.PruneDead(time.Now().Add(time.Hour)))
+	dead, err = store.ListDead()
+	assert.NoError(t, err)
+	assert.Empty(t, dead)
+}
+
+func TestBoltStoreKeepsFinishedJobs(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "queue.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	old := NewJob("publish")
+	old.Status = STATUS_DONE
+	old.FinishedAt = time.Now().Add(-48 * time.Hour)
+	recent := NewJob("publish")
+	recent.Status = STATUS_CANCELLED
+	recent.FinishedAt = time.Now()
+
+	assert.NoError(t, store.PutFinished(old))
+	assert.NoError(t, store.PutFinished(recent))
+	assert.NoError(t, store.PruneFinished(time.Now().Add(-24*time.Hour)))
+
+	finished, err := store.ListFinished()
+	assert.NoError(t, err)
+	assert.Len(t, finished, 1)
+	assert.Equal(t, recent.ID, finished[0].ID)
+	assert.Equal(t, STATUS_CANCELLED, finished[0].Status)
+
+	stored, err := store.GetFinished(recent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, STATUS_CANCELLED, stored.Status)
+	stored, err = store.GetFinished(old.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, stored)
+}
@@ -3,12 +3,16 @@ package scm
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/google/go-github/v41/github"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 	"github.com/sourcegraph/go-diff/diff"
 	"golang.org/x/oauth2"
 
@@ -23,6 +27,23 @@ const (
 type Github struct {
 	client *github.Client
 	sonar  *sonarqube.Sonarqube
+
+	secondaryLocationComments bool
+	summaryMetrics            []string
+	transport                 http.RoundTripper
+
+	// login is the user of the token, only its comments are trusted to carry markers
+	loginMutex sync.Mutex
+	login      string
+}
+
+type GithubOption func(*Github)
+
+// WithSecondaryLocationComments also comments on the secondary locations that are part of the PR diff
+func WithSecondaryLocationComments(enabled bool) GithubOption {
+	return func(g *Github) {
+		g.secondaryLocationComments = enabled
+	}
 }
 
 type GithubPath struct {
@@ -30,26 +51,81 @@ type GithubPath struct {
 	Repo  string
 }
 
-func NewGithub(ctx context.Context, sonar *sonarqube.Sonarqube, token string) *Github {
+// WithSummaryMeasures comments a table with the given PR metrics in the PR conversation
+func WithSummaryMeasures(metricKeys []string) GithubOption {
+	return func(g *Github) {
+		g.summaryMetrics = metricKeys
+	}
+}
+
+// WithTransport uses the given round tripper underneath the authentication
+func WithTransport(rt http.RoundTripper) GithubOption {
+	return func(g *Github) {
+		g.transport = rt
+	}
+}
+
+func NewGithub(ctx context.Context, sonar *sonarqube.Sonarqube, token string, opts ...GithubOption) *Github {
+	gh := &Github{
+		sonar: sonar,
+	}
+
+	for _, opt := range opts {
+		opt(gh)
+	}
+
 	// Token source
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
 
+	// Oauth2 uses the client from the context as base
+	if gh.transport != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: gh.transport})
+	}
+
 	// Oauth2 client
 	tc := oauth2.NewClient(ctx, ts)
 
 	// Github client
-	client := github.NewClient(tc)
+	gh.client = github.NewClient(tc)
+
+	return gh
+}
+
+// CheckCredentials checks the token is accepted by GitHub, returning the remaining API rate
+func (g *Github) CheckCredentials(ctx context.Context) (*github.Rate, error) {
+	limits, _, err := g.client.RateLimits(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the rate limit")
+	}
+	if limits.GetCore() == nil {
+		return nil, errors.New("missing the core rate limit")
+	}
 
-	return &Github{
-		client: client,
-		sonar:  sonar,
+	return limits.GetCore(), nil
+}
+
+// Login returns the user the token belongs to, looked up only once
+func (g *Github) Login(ctx context.Context) (string, error) {
+	g.loginMutex.Lock()
+	defer g.loginMutex.Unlock()
+
+	if g.login != "" {
+		return g.login, nil
+	}
+
+	user, _, err := g.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get the authenticated user")
 	}
+	g.login = user.GetLogin()
+
+	return g.login, nil
 }
 
 // PublishIssuesReviewFor publishes a review with a comment for each issue
-func (g *Github) PublishIssuesReviewFor(ctx context.Context, issues []sonarqube.Issue, pr *sonarqube.PullRequest, requestChanges bool) error {
+func (g *Github) PublishIssuesReviewFor(ctx context.Context, issues []sonarqube.Issue, pr *sonarqube.PullRequest, requestChanges bool) (*Review, error) {
 	var reviewEvent string
 	if requestChanges {
 		reviewEvent = REVIEW_EVENT_REQUEST_CHANGES
@@ -60,34 +136,37 @@ func (g *Github) PublishIssuesReviewFor(ctx context.Context, issues []sonarqube.
 	// Convert PR number into int
 	prNumber, err := strconv.Atoi(pr.Key)
 	if err != nil {
-		return errors.Wrap(err, "failed to convert PR number to int")
+		return nil, errors.Wrap(err, "failed to convert PR number to int")
 	}
 
 	// Parse PR path
 	ghPath, err := parseGithubPath(pr.URL)
 	if err != nil {
-		return errors.Wrap(err, "failed to parse github path")
+		return nil, errors.Wrap(err, "failed to parse github path")
 	}
 
 	// Fetch PR diffs
-	ghDiff, _, err := g.client.PullRequests.GetRaw(ctx, ghPath.Owner, ghPath.Repo, prNumber, github.RawOptions{Type: github.Diff})
+	diffMap, err := g.pullRequestDiff(ctx, ghPath, prNumber)
 	if err != nil {
-		return errors.Wrap(err, "failed to get raw PR")
+		return nil, err
 	}
 
-	// Parse diffs
-	fileDiffs, err := diff.ParseMultiFileDiff([]byte(ghDiff))
-	if err != nil {
-		return errors.Wrap(err, "failed to parse diff")
-	}
+	// Head commit is only needed to link secondary locations
+	var headSHA string
+	for _, issue := range issues {
+		if len(issue.SecondaryLocations()) > 0 {
+			headSHA, err = g.headSHA(ctx, ghPath, prNumber)
+			if err != nil {
+				return nil, err
+			}
 
-	diffMap := make(map[string][]*diff.Hunk)
-	for i := range fileDiffs {
-		fileName := fileDiffs[i].OrigName[2:]
-		diffMap[fileName] = fileDiffs[i].Hunks
+			break
+		}
 	}
 
 	comments := make([]*github.DraftReviewComment, 0)
+	hasIssueMarkers := false
+	skipped := make([]string, 0)
 
 	// Create a comment for each issue
 	for _, issue := range issues {
@@ -98,34 +177,266 @@ func (g *Github) PublishIssuesReviewFor(ctx context.Context, issues []sonarqube.
 
 		// Skip if current issue is not part of the PR diff
 		hunks, ok := diffMap[filePath]
-		if !ok {
+		if !ok || !isLineInHunks(hunks, lineNumber) {
+			skipped = append(skipped, issue.Key)
+
 			continue
 		}
 
-		for _, hunk := range hunks {
-			if lineNumber < int(hunk.OrigStartLine) || lineNumber < int(hunk.NewStartLine) {
+		// Secondary locations
+		locations := issue.SecondaryLocations()
+		if len(locations) > 0 {
+			message = fmt.Sprintf("%s\n\n%s", message, locationsMarkdown(pr.URL, ghPath, headSHA, issue.Project, locations))
+		}
+
+		// Identify the comment to link it back to the issue
+		if issue.Key != "" {
+			message = fmt.Sprintf("%s\n\n%s", message, marker(MARKER_ISSUE, issue.Key))
+			hasIssueMarkers = true
+		}
+
+		comment := &github.DraftReviewComment{
+			Path: &filePath,
+			Body: &message,
+			Side: &side,
+			Line: &lineNumber,
+		}
+		comments = append(comments, comment)
+
+		if !g.secondaryLocationComments {
+			continue
+		}
+
+		// Point the secondary locations that are part of the diff back to the primary one
+		for _, location := range locations {
+			locationPath := location.FilePath(issue.Project)
+			locationLine := location.Line()
+			if locationPath == filePath && locationLine == lineNumber {
 				continue
 			}
-			if lineNumber > int(hunk.OrigStartLine+hunk.OrigLines) || lineNumber > int(hunk.NewStartLine+hunk.NewLines) {
+
+			locationHunks, ok := diffMap[locationPath]
+			if !ok || !isLineInHunks(locationHunks, locationLine) {
 				continue
 			}
 
-			comment := &github.DraftReviewComment{
-				Path: &filePath,
-				Body: &message,
+			locationMessage := fmt.Sprintf(
+				":link: Secondary location of %s: %s",
+				locationLink(pr.URL, ghPath, headSHA, filePath, lineNumber),
+				issue.MarkdownMessage(g.sonar.Root),
+			)
+			if location.Message != "" {
+				locationMessage = fmt.Sprintf("%s\n\n> %s", locationMessage, location.Message)
+			}
+
+			comments = append(comments, &github.DraftReviewComment{
+				Path: &locationPath,
+				Body: &locationMessage,
 				Side: &side,
-				Line: &lineNumber,
+				Line: &locationLine,
+			})
+		}
+	}
+
+	if len(comments) == 0 {
+		return nil, ErrNoRelevantIssues
+	}
+
+	body := fmt.Sprintf(`:wave: Hey, I added %d comments about your changes, please take a look :slightly_smiling_face:`, len(comments))
+
+	reviewRequest := &github.PullRequestReviewRequest{
+		Body:     &body,
+		Event:    &reviewEvent,
+		Comments: comments,
+	}
+
+	// Create the review
+	review, _, err := g.client.PullRequests.CreateReview(ctx, ghPath.Owner, ghPath.Repo, prNumber, reviewRequest)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create review")
+	}
+
+	result := &Review{URL: review.GetHTMLURL(), IssueComments: make(map[string]string), Skipped: skipped}
+	if !hasIssueMarkers {
+		return result, nil
+	}
+
+	// The review is already published, the comment links are a best effort
+	result.IssueComments, err = g.reviewIssueComments(ctx, ghPath, prNumber, review.GetID())
+	if err != nil {
+		logrus.WithError(err).Warnln("Failed to link the issues to the review comments")
+	}
+
+	return result, nil
+}
+
+// PublishHotspotsReviewFor publishes a review with a comment for each security hotspot that wasn't published yet
+func (g *Github) PublishHotspotsReviewFor(ctx context.Context, hotspots []sonarqube.Hotspot, pr *sonarqube.PullRequest) error {
+	reviewEvent := REVIEW_EVENT_COMMENT
+
+	// Convert PR number into int
+	prNumber, err := strconv.Atoi(pr.Key)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert PR number to int")
+	}
+
+	// Parse PR path
+	ghPath, err := parseGithubPath(pr.URL)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse github path")
+	}
+
+	// Fetch PR diffs
+	diffMap, err := g.pullRequestDiff(ctx, ghPath, prNumber)
+	if err != nil {
+		return err
+	}
+
+	// Hotspots can't be tagged, so the comments already in the PR tell what was published
+	published, err := g.publishedMarkers(ctx, ghPath, prNumber)
+	if err != nil {
+		return err
+	}
+
+	comments := make([]*github.DraftReviewComment, 0)
+
+	// Create a comment for each hotspot
+	for _, hotspot := range hotspots {
+		side := "RIGHT"
+		hotspotMarker := marker(MARKER_HOTSPOT, hotspot.Key)
+		message := fmt.Sprintf("%s\n\n%s", hotspot.MarkdownMessage(g.sonar.Root, pr.Key), hotspotMarker)
+		filePath := hotspot.FilePath()
+		lineNumber := hotspot.Line
+
+		// Skip if already published
+		if published[hotspotMarker] {
+			continue
+		}
+
+		// Skip if current hotspot is not part of the PR diff
+		hunks, ok := diffMap[filePath]
+		if !ok || !isLineInHunks(hunks, lineNumber) {
+			continue
+		}
+
+		comments = append(comments, &github.DraftReviewComment{
+			Path: &filePath,
+			Body: &message,
+			Side: &side,
+			Line: &lineNumber,
+		})
+	}
+
+	// Nothing new to publish
+	if len(comments) == 0 {
+		return nil
+	}
+
+	body := fmt.Sprintf(`:closed_lock_with_key: Hey, I found %d security hotspots in your changes, please review them :slightly_smiling_face:`, len(comments))
+
+	reviewRequest := &github.PullRequestReviewRequest{
+		Body:     &body,
+		Event:    &reviewEvent,
+		Comments: comments,
+	}
+
+	// Create the review
+	_, _, err = g.client.PullRequests.CreateReview(ctx, ghPath.Owner, ghPath.Repo, prNumber, reviewRequest)
+	if err != nil {
+		return errors.Wrap(err, "failed to create hotspots review")
+	}
+
+	return nil
+}
+
+// PublishCoverageReviewFor publishes a review with a comment for each range of uncovered lines added by the PR,
+// up to maxComments coverage comments in the PR
+func (g *Github) PublishCoverageReviewFor(ctx context.Context, project string, pr *sonarqube.PullRequest, maxComments int) error {
+	reviewEvent := REVIEW_EVENT_COMMENT
+
+	// Convert PR number into int
+	prNumber, err := strconv.Atoi(pr.Key)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert PR number to int")
+	}
+
+	// Parse PR path
+	ghPath, err := parseGithubPath(pr.URL)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse github path")
+	}
+
+	// Fetch PR diffs
+	diffMap, err := g.pullRequestDiff(ctx, ghPath, prNumber)
+	if err != nil {
+		return err
+	}
+
+	// Coverage comments already in the PR count towards the limit
+	published, err := g.publishedMarkers(ctx, ghPath, prNumber)
+	if err != nil {
+		return err
+	}
+	remaining := maxComments - countMarkers(published, MARKER_COVERAGE)
+
+	// Sort files to keep the comments stable when the limit is reached
+	filePaths := make([]string, 0, len(diffMap))
+	for filePath := range diffMap {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	comments := make([]*github.DraftReviewComment, 0)
+
+	for _, filePath := range filePaths {
+		if len(comments) >= remaining {
+			break
+		}
+
+		added := addedLines(diffMap[filePath])
+		if len(added) == 0 {
+			continue
+		}
+
+		// Read file coverage
+		component := fmt.Sprintf("%s:%s", project, filePath)
+		sourceLines, err := g.sonar.SourceLinesContext(ctx, component, pr.Key)
+		if notIndexed(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to read coverage of %s", component))
+		}
+
+		// Keep only the uncovered lines added by the PR
+		uncovered := make([]int, 0)
+		for _, line := range sourceLines.Uncovered() {
+			if added[line] {
+				uncovered = append(uncovered, line)
 			}
-			comments = append(comments, comment)
 		}
 
+		// Create a comment for each range of uncovered lines
+		for _, lineRange := range sonarqube.CollapseLines(uncovered) {
+			if len(comments) >= remaining {
+				break
+			}
+
+			coverageMarker := marker(MARKER_COVERAGE, fmt.Sprintf("%s#L%d-L%d", filePath, lineRange.Start, lineRange.End))
+			if published[coverageMarker] {
+				continue
+			}
+
+			comments = append(comments, coverageComment(filePath, lineRange, coverageMarker))
+		}
 	}
 
+	// Nothing new to publish
 	if len(comments) == 0 {
-		return errors.New("failed to find relevant issues")
+		return nil
 	}
 
-	body := fmt.Sprintf(`:wave: Hey, I added %d comments about your changes, please take a look :slightly_smiling_face:`, len(comments))
+	body := fmt.Sprintf(`:umbrella: Hey, I found %d blocks of new code not covered by tests, please take a look :slightly_smiling_face:`, len(comments))
 
 	reviewRequest := &github.PullRequestReviewRequest{
 		Body:     &body,
@@ -136,12 +447,440 @@ func (g *Github) PublishIssuesReviewFor(ctx context.Context, issues []sonarqube.
 	// Create the review
 	_, _, err = g.client.PullRequests.CreateReview(ctx, ghPath.Owner, ghPath.Repo, prNumber, reviewRequest)
 	if err != nil {
-		return errors.Wrap(err, "failed to create review")
+		return errors.Wrap(err, "failed to create coverage review")
 	}
 
 	return nil
 }
 
+// PublishDuplicationsReviewFor publishes a review with a comment for each duplicated block added by the PR,
+// linking the other occurrences of the block
+func (g *Github) PublishDuplicationsReviewFor(ctx context.Context, project string, pr *sonarqube.PullRequest) error {
+	reviewEvent := REVIEW_EVENT_COMMENT
+
+	// Convert PR number into int
+	prNumber, err := strconv.Atoi(pr.Key)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert PR number to int")
+	}
+
+	// Parse PR path
+	ghPath, err := parseGithubPath(pr.URL)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse github path")
+	}
+
+	// Fetch PR diffs
+	diffMap, err := g.pullRequestDiff(ctx, ghPath, prNumber)
+	if err != nil {
+		return err
+	}
+
+	// Duplications already commented
+	published, err := g.publishedMarkers(ctx, ghPath, prNumber)
+	if err != nil {
+		return err
+	}
+
+	// Sort files to keep the comments stable
+	filePaths := make([]string, 0, len(diffMap))
+	for filePath := range diffMap {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	var headSHA string
+	comments := make([]*github.DraftReviewComment, 0)
+
+	for _, filePath := range filePaths {
+		added := addedLines(diffMap[filePath])
+		if len(added) == 0 {
+			continue
+		}
+
+		// Read file duplications
+		component := fmt.Sprintf("%s:%s", project, filePath)
+		duplications, err := g.sonar.DuplicationsContext(ctx, component, pr.Key)
+		if notIndexed(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("failed to read duplications of %s", component))
+		}
+
+		for _, duplication := range duplications.Duplications {
+			for i, block := range duplication.Blocks {
+				if duplications.File(block).Key != component {
+					continue
+				}
+
+				// Only the lines added by the PR can be commented
+				blockAdded := make([]int, 0)
+				for line := block.From; line <= block.To(); line++ {
+					if added[line] {
+						blockAdded = append(blockAdded, line)
+					}
+				}
+				if len(blockAdded) == 0 {
+					continue
+				}
+
+				duplicationMarker := marker(MARKER_DUPLICATION, fmt.Sprintf("%s#L%d-L%d", filePath, block.From, block.To()))
+				if published[duplicationMarker] {
+					continue
+				}
+
+				// Other occurrences are linked in the head commit
+				if headSHA == "" {
+					headSHA, err = g.headSHA(ctx, ghPath, prNumber)
+					if err != nil {
+						return err
+					}
+				}
+
+				occurrences := make([]string, 0, len(duplication.Blocks)-1)
+				for j, other := range duplication.Blocks {
+					if i == j {
+						continue
+					}
+
+					otherFile := duplications.File(other)
+					if otherFile.Project != project {
+						occurrences = append(occurrences, fmt.Sprintf("- `%s` L%d-L%d (project %s)", otherFile.FilePath(), other.From, other.To(), otherFile.Project))
+
+						continue
+					}
+
+					occurrenceURL := fmt.Sprintf("%s-L%d", blobURL(pr.URL, ghPath, headSHA, otherFile.FilePath(), other.From), other.To())
+					occurrences = append(occurrences, fmt.Sprintf("- [%s:%d-%d](%s)", otherFile.FilePath(), other.From, other.To(), occurrenceURL))
+				}
+
+				// Anchor the comment in the first range of added lines, multi-line comments can't span hunks
+				lineRange := sonarqube.CollapseLines(blockAdded)[0]
+				message := fmt.Sprintf(
+					":busts_in_silhouette: Lines %d to %d are duplicated in:\n%s\n\n%s",
+					block.From, block.To(), strings.Join(occurrences, "\n"), duplicationMarker,
+				)
+
+				comments = append(comments, rangeComment(filePath, lineRange, message))
+			}
+		}
+	}
+
+	// Nothing new to publish
+	if len(comments) == 0 {
+		return nil
+	}
+
+	body := fmt.Sprintf(`:busts_in_silhouette: Hey, I found %d duplicated blocks in your changes, please take a look :slightly_smiling_face:`, len(comments))
+
+	reviewRequest := &github.PullRequestReviewRequest{
+		Body:     &body,
+		Event:    &reviewEvent,
+		Comments: comments,
+	}
+
+	// Create the review
+	_, _, err = g.client.PullRequests.CreateReview(ctx, ghPath.Owner, ghPath.Repo, prNumber, reviewRequest)
+	if err != nil {
+		return errors.Wrap(err, "failed to create duplications review")
+	}
+
+	return nil
+}
+
+// coverageComment creates the review comment for the given range of uncovered lines
+func coverageComment(filePath string, lineRange sonarqube.LineRange, coverageMarker string) *github.DraftReviewComment {
+	if lineRange.Start == lineRange.End {
+		return rangeComment(filePath, lineRange, fmt.Sprintf(":umbrella: Line %d is not covered by tests\n\n%s", lineRange.Start, coverageMarker))
+	}
+
+	return rangeComment(filePath, lineRange, fmt.Sprintf(":umbrella: Lines %d to %d are not covered by tests\n\n%s", lineRange.Start, lineRange.End, coverageMarker))
+}
+
+// rangeComment creates a review comment for the given range of lines
+func rangeComment(filePath string, lineRange sonarqube.LineRange, message string) *github.DraftReviewComment {
+	side := "RIGHT"
+	line := lineRange.End
+
+	if lineRange.Start == lineRange.End {
+		return &github.DraftReviewComment{
+			Path: &filePath,
+			Body: &message,
+			Side: &side,
+			Line: &line,
+		}
+	}
+
+	startLine := lineRange.Start
+
+	return &github.DraftReviewComment{
+		Path:      &filePath,
+		Body:      &message,
+		StartSide: &side,
+		Side:      &side,
+		StartLine: &startLine,
+		Line:      &line,
+	}
+}
+
+// PublishSummaryFor comments the PR measures in the PR conversation, the comment of a previous
+// analysis is updated instead of adding a new one
+func (g *Github) PublishSummaryFor(ctx context.Context, project string, pr *sonarqube.PullRequest) error {
+	if len(g.summaryMetrics) == 0 {
+		return nil
+	}
+
+	// Convert PR number into int
+	prNumber, err := strconv.Atoi(pr.Key)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert PR number to int")
+	}
+
+	// Parse PR path
+	ghPath, err := parseGithubPath(pr.URL)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse github path")
+	}
+
+	summary, err := g.sonar.PullRequestSummaryContext(ctx, project, pr, g.summaryMetrics)
+	if err != nil {
+		return errors.Wrap(err, "failed to summarize PR measures")
+	}
+	if summary == "" {
+		return nil
+	}
+
+	summaryMarker := marker(MARKER_SUMMARY, pr.Key)
+	body := fmt.Sprintf(":bar_chart: Sonarqube measures of this PR\n\n%s\n\n%s", summary, summaryMarker)
+
+	commentID, err := g.markedConversationComment(ctx, ghPath, prNumber, summaryMarker)
+	if err != nil {
+		return err
+	}
+
+	if commentID == 0 {
+		_, _, err = g.client.Issues.CreateComment(ctx, ghPath.Owner, ghPath.Repo, prNumber, &github.IssueComment{Body: &body})
+		if err != nil {
+			return errors.Wrap(err, "failed to comment the summary")
+		}
+
+		return nil
+	}
+
+	_, _, err = g.client.Issues.EditComment(ctx, ghPath.Owner, ghPath.Repo, commentID, &github.IssueComment{Body: &body})
+	if err != nil {
+		return errors.Wrap(err, "failed to update the summary")
+	}
+
+	return nil
+}
+
+// pullRequestDiff fetches the PR diff and maps the hunks by the file name in the PR head, where Sonarqube
+// reports the issues, so renamed files are matched by their new name and added files are included
+func (g *Github) pullRequestDiff(ctx context.Context, ghPath *GithubPath, prNumber int) (map[string][]*diff.Hunk, error) {
+	// Fetch PR diffs
+	ghDiff, _, err := g.client.PullRequests.GetRaw(ctx, ghPath.Owner, ghPath.Repo, prNumber, github.RawOptions{Type: github.Diff})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get raw PR")
+	}
+
+	// Parse diffs
+	fileDiffs, err := diff.ParseMultiFileDiff([]byte(ghDiff))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse diff")
+	}
+
+	diffMap := make(map[string][]*diff.Hunk)
+	for i := range fileDiffs {
+		// Deleted files have nothing to comment on
+		if fileDiffs[i].NewName == "/dev/null" {
+			continue
+		}
+
+		fileName := fileDiffs[i].NewName[2:]
+		diffMap[fileName] = fileDiffs[i].Hunks
+	}
+
+	return diffMap, nil
+}
+
+// headSHA reads the head commit of the PR
+func (g *Github) headSHA(ctx context.Context, ghPath *GithubPath, prNumber int) (string, error) {
+	ghPR, _, err := g.client.PullRequests.Get(ctx, ghPath.Owner, ghPath.Repo, prNumber)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get PR head")
+	}
+
+	return ghPR.GetHead().GetSHA(), nil
+}
+
+// publishedMarkers reads the markers of the review comments sqpr already added to the PR,
+// the ones pasted by other users are ignored so they can't stop the findings from being published
+func (g *Github) publishedMarkers(ctx context.Context, ghPath *GithubPath, prNumber int) (map[string]bool, error) {
+	login, err := g.Login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	markers := make(map[string]bool)
+
+	opts := &github.PullRequestListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		comments, res, err := g.client.PullRequests.ListComments(ctx, ghPath.Owner, ghPath.Repo, prNumber, opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list PR comments")
+		}
+
+		for _, comment := range comments {
+			if comment.GetUser().GetLogin() != login {
+				continue
+			}
+
+			for _, commentMarker := range findMarkers(comment.GetBody()) {
+				markers[commentMarker] = true
+			}
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opts.Page = res.NextPage
+	}
+
+	return markers, nil
+}
+
+// markedConversationComment returns the ID of the PR conversation comment sqpr added with the given marker, zero when there is none
+func (g *Github) markedConversationComment(ctx context.Context, ghPath *GithubPath, prNumber int, commentMarker string) (int64, error) {
+	login, err := g.Login(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	opts := &github.IssueListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		comments, res, err := g.client.Issues.ListComments(ctx, ghPath.Owner, ghPath.Repo, prNumber, opts)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to list PR conversation comments")
+		}
+
+		for _, comment := range comments {
+			// Someone else may have quoted the summary
+			if comment.GetUser().GetLogin() != login {
+				continue
+			}
+
+			for _, found := range findMarkers(comment.GetBody()) {
+				if found == commentMarker {
+					return comment.GetID(), nil
+				}
+			}
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opts.Page = res.NextPage
+	}
+
+	return 0, nil
+}
+
+// reviewIssueComments returns the URL of each issue comment of the given review by the issue key
+func (g *Github) reviewIssueComments(ctx context.Context, ghPath *GithubPath, prNumber int, reviewID int64) (map[string]string, error) {
+	issueComments := make(map[string]string)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		comments, res, err := g.client.PullRequests.ListReviewComments(ctx, ghPath.Owner, ghPath.Repo, prNumber, reviewID, opts)
+		if err != nil {
+			return issueComments, errors.Wrap(err, "failed to list review comments")
+		}
+
+		for _, comment := range comments {
+			for _, commentMarker := range findMarkers(comment.GetBody()) {
+				if key, ok := markerKey(commentMarker, MARKER_ISSUE); ok {
+					issueComments[key] = comment.GetHTMLURL()
+				}
+			}
+		}
+
+		if res.NextPage == 0 {
+			break
+		}
+		opts.Page = res.NextPage
+	}
+
+	return issueComments, nil
+}
+
+// isLineInHunks checks if the given line of the PR head is part of one of the given hunks,
+// only the new side matters as the comments are added to the right side of the diff
+func isLineInHunks(hunks []*diff.Hunk, lineNumber int) bool {
+	for _, hunk := range hunks {
+		if lineNumber < int(hunk.NewStartLine) || lineNumber >= int(hunk.NewStartLine+hunk.NewLines) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// addedLines returns the lines of the new file version that were added in the given hunks
+func addedLines(hunks []*diff.Hunk) map[int]bool {
+	lines := make(map[int]bool)
+	for _, hunk := range hunks {
+		lineNumber := int(hunk.NewStartLine)
+		for _, line := range strings.Split(string(hunk.Body), "\n") {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				lines[lineNumber] = true
+				lineNumber++
+			case strings.HasPrefix(line, " "):
+				lineNumber++
+			}
+		}
+	}
+
+	return lines
+}
+
+// locationsMarkdown creates an ordered list linking each of the given locations
+func locationsMarkdown(prURL string, ghPath *GithubPath, sha string, project string, locations []sonarqube.Location) string {
+	lines := make([]string, 0, len(locations))
+	for i, location := range locations {
+		line := fmt.Sprintf("%d. %s", i+1, locationLink(prURL, ghPath, sha, location.FilePath(project), location.Line()))
+		if location.Message != "" {
+			line = fmt.Sprintf("%s — %s", line, location.Message)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// locationLink creates a markdown link to the given file line at the given commit
+func locationLink(prURL string, ghPath *GithubPath, sha string, filePath string, line int) string {
+	return fmt.Sprintf("[%s:%d](%s)", filePath, line, blobURL(prURL, ghPath, sha, filePath, line))
+}
+
+// blobURL creates the url to the given file line at the given commit, in the same host of the PR
+func blobURL(prURL string, ghPath *GithubPath, sha string, filePath string, line int) string {
+	host := "https://github.com"
+	if parsedUrl, err := url.Parse(prURL); err == nil && parsedUrl.Host != "" {
+		host = fmt.Sprintf("%s://%s", parsedUrl.Scheme, parsedUrl.Host)
+	}
+
+	return fmt.Sprintf("%s/%s/%s/blob/%s/%s#L%d", host, ghPath.Owner, ghPath.Repo, sha, filePath, line)
+}
+
 // parseGithubPath converts the given path into GitHub path struct
 func parseGithubPath(path string) (*GithubPath, error) {
 	// Parse url
@@ -167,3 +906,10 @@ func parseGithubPath(path string) (*GithubPath, error) {
 		}, nil
 	}
 }
+
+// notIndexed checks if Sonarqube doesn't know the file, like the docs, the dependency manifests or the excluded paths
+func notIndexed(err error) bool {
+	var apiErr *sonarqube.APIError
+
+	return errors.As(err, &apiErr) && apiErr.IsNotFound()
+}
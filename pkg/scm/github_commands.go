@@ -0,0 +1,114 @@
+package scm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/pkg/errors"
+)
+
+const (
+	GITHUB_EVENT_ISSUE_COMMENT = "issue_comment"
+
+	COMMAND_PREFIX        = "/sqpr"
+	COMMAND_REPUBLISH     = "republish"
+	COMMAND_IGNORE        = "ignore"
+	COMMAND_FALSEPOSITIVE = "falsepositive"
+	COMMAND_SUMMARY       = "summary"
+
+	REACTION_SEEN     = "eyes"
+	REACTION_DONE     = "+1"
+	REACTION_DENIED   = "-1"
+	REACTION_CONFUSED = "confused"
+)
+
+// Command is a slash command like `/sqpr ignore go:S1234`
+type Command struct {
+	Name string
+	Args []string
+}
+
+// CommandEvent is a command sent in a PR conversation comment
+type CommandEvent struct {
+	Owner     string
+	Repo      string
+	PRNumber  int
+	User      string
+	CommentID int64
+	Command   *Command
+}
+
+// ParseCommand reads the command from the first line of the given comment body
+func ParseCommand(body string) (*Command, bool) {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(body), "\n", 2)[0])
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != COMMAND_PREFIX {
+		return nil, false
+	}
+
+	return &Command{Name: strings.ToLower(fields[1]), Args: fields[2:]}, true
+}
+
+// CommandFor reads the command from the given webhook, nil is returned when the event isn't a command in a PR
+func (g *Github) CommandFor(eventType string, payload []byte) (*CommandEvent, error) {
+	if eventType != GITHUB_EVENT_ISSUE_COMMENT {
+		return nil, nil
+	}
+
+	var event github.IssueCommentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.Wrap(err, "failed to parse issue comment event")
+	}
+
+	// Only new comments by people in PRs
+	if event.GetAction() != "created" || !event.GetIssue().IsPullRequest() || event.GetSender().GetType() == "Bot" {
+		return nil, nil
+	}
+
+	command, ok := ParseCommand(event.GetComment().GetBody())
+	if !ok {
+		return nil, nil
+	}
+
+	return &CommandEvent{
+		Owner:     event.GetRepo().GetOwner().GetLogin(),
+		Repo:      event.GetRepo().GetName(),
+		PRNumber:  event.GetIssue().GetNumber(),
+		User:      event.GetSender().GetLogin(),
+		CommentID: event.GetComment().GetID(),
+		Command:   command,
+	}, nil
+}
+
+// ReactToComment adds the given reaction to a PR conversation comment
+func (g *Github) ReactToComment(ctx context.Context, owner string, repo string, commentID int64, reaction string) error {
+	_, _, err := g.client.Reactions.CreateIssueCommentReaction(ctx, owner, repo, commentID, reaction)
+	if err != nil {
+		return errors.Wrap(err, "failed to react to comment")
+	}
+
+	return nil
+}
+
+// ReactToReviewComment adds the given reaction to a PR review comment
+func (g *Github) ReactToReviewComment(ctx context.Context, owner string, repo string, commentID int64, reaction string) error {
+	_, _, err := g.client.Reactions.CreatePullRequestCommentReaction(ctx, owner, repo, commentID, reaction)
+	if err != nil {
+		return errors.Wrap(err, "failed to react to review comment")
+	}
+
+	return nil
+}
+
+// Reply adds a comment into the PR conversation
+func (g *Github) Reply(ctx context.Context, owner string, repo string, prNumber int, body string) error {
+	_, _, err := g.client.Issues.CreateComment(ctx, owner, repo, prNumber, &github.IssueComment{Body: &body})
+	if err != nil {
+		return errors.Wrap(err, "failed to reply")
+	}
+
+	return nil
+}
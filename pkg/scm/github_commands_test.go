@@ -0,0 +1,100 @@
+package scm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommand(t *testing.T) {
+	command, ok := ParseCommand("/sqpr ignore go:S1234\n\nIt's generated code")
+	assert.True(t, ok)
+	assert.Equal(t, &Command{Name: COMMAND_IGNORE, Args: []string{"go:S1234"}}, command)
+
+	command, ok = ParseCommand("  /sqpr Republish  ")
+	assert.True(t, ok)
+	assert.Equal(t, COMMAND_REPUBLISH, command.Name)
+	assert.Empty(t, command.Args)
+
+	_, ok = ParseCommand("/sqpr")
+	assert.False(t, ok)
+	_, ok = ParseCommand("Please run /sqpr summary")
+	assert.False(t, ok)
+	_, ok = ParseCommand("/sqprsummary")
+	assert.False(t, ok)
+}
+
+func TestGithubCommandFor(t *testing.T) {
+	gh := &Github{}
+
+	payload := []byte(`{
+		"action": "created",
+		"issue": {"number": 3, "pull_request": {"url": "https://api.github.com/repos/herlon214/sonarqube-pr-issues/pulls/3"}},
+		"comment": {"id": 70, "body": "/sqpr summary"},
+		"repository": {"name": "sonarqube-pr-issues", "owner": {"login": "herlon214"}},
+		"sender": {"login": "dev", "type": "User"}
+	}`)
+
+	event, err := gh.CommandFor(GITHUB_EVENT_ISSUE_COMMENT, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, &CommandEvent{
+		Owner:     "herlon214",
+		Repo:      "sonarqube-pr-issues",
+		PRNumber:  3,
+		User:      "dev",
+		CommentID: 70,
+		Command:   &Command{Name: COMMAND_SUMMARY, Args: []string{}},
+	}, event)
+
+	// Comments in issues aren't commands
+	event, err = gh.CommandFor(GITHUB_EVENT_ISSUE_COMMENT, []byte(`{"action": "created", "issue": {"number": 4}, "comment": {"id": 71, "body": "/sqpr summary"}, "sender": {"login": "dev", "type": "User"}}`))
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+
+	// Regular comments
+	event, err = gh.CommandFor(GITHUB_EVENT_ISSUE_COMMENT, []byte(`{"action": "created", "issue": {"number": 3, "pull_request": {}}, "comment": {"id": 72, "body": "LGTM"}, "sender": {"login": "dev", "type": "User"}}`))
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+
+	// Other events
+	event, err = gh.CommandFor(GITHUB_EVENT_REVIEW_COMMENT, payload)
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestGithubReactAndReply(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsReactionsByOwnerByRepoByCommentId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, "/repos/owner/repo/issues/comments/70/reactions", r.URL.Path)
+				assert.Equal(t, `{"content":"+1"}`+"\n", string(body))
+
+				w.Write([]byte(`{"id":1,"content":"+1"}`))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, "/repos/owner/repo/issues/3/comments", r.URL.Path)
+				assert.Equal(t, `{"body":"Ignored 2 issues"}`+"\n", string(body))
+
+				w.Write([]byte(`{"id":71}`))
+			}),
+		),
+	)
+
+	gh := &Github{client: github.NewClient(mockedHTTPClient)}
+
+	assert.NoError(t, gh.ReactToComment(context.Background(), "owner", "repo", 70, REACTION_DONE))
+	assert.NoError(t, gh.Reply(context.Background(), "owner", "repo", 3, "Ignored 2 issues"))
+}
@@ -0,0 +1,167 @@
+package scm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/pkg/errors"
+)
+
+const (
+	GITHUB_EVENT_REVIEW_COMMENT = "pull_request_review_comment"
+	GITHUB_EVENT_REVIEW_THREAD  = "pull_request_review_thread"
+)
+
+// PullRequestReviewThreadEvent is sent when a review thread is resolved or unresolved, go-github doesn't support it yet
+type PullRequestReviewThreadEvent struct {
+	Action      *string             `json:"action,omitempty"`
+	Thread      *PullRequestThread  `json:"thread,omitempty"`
+	PullRequest *github.PullRequest `json:"pull_request,omitempty"`
+	Repo        *github.Repository  `json:"repository,omitempty"`
+	Sender      *github.User        `json:"sender,omitempty"`
+}
+
+// PullRequestThread is a review thread, the first comment is the one that started it
+type PullRequestThread struct {
+	NodeID   *string                      `json:"node_id,omitempty"`
+	Comments []*github.PullRequestComment `json:"comments,omitempty"`
+}
+
+// ThreadFeedback is a developer reply or resolution on a thread started by an issue comment
+type ThreadFeedback struct {
+	Owner    string
+	Repo     string
+	User     string
+	IssueKey string
+	// CommentID is the reply comment, zero when the thread was resolved
+	CommentID int64
+	// Reply is the reply text, empty when the thread was resolved
+	Reply    string
+	Resolved bool
+}
+
+// ThreadFeedbackFor reads the feedback from the given webhook, nil is returned when the event
+// isn't about a thread started by an issue comment
+func (g *Github) ThreadFeedbackFor(ctx context.Context, eventType string, payload []byte) (*ThreadFeedback, error) {
+	switch eventType {
+	case GITHUB_EVENT_REVIEW_COMMENT:
+		var event github.PullRequestReviewCommentEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, errors.Wrap(err, "failed to parse review comment event")
+		}
+
+		// Only new replies by people
+		comment := event.GetComment()
+		if event.GetAction() != "created" || comment.GetInReplyTo() == 0 || event.GetSender().GetType() == "Bot" {
+			return nil, nil
+		}
+
+		owner := event.GetRepo().GetOwner().GetLogin()
+		repo := event.GetRepo().GetName()
+
+		// The marker is in the comment that started the thread
+		root, _, err := g.client.PullRequests.GetComment(ctx, owner, repo, comment.GetInReplyTo())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the thread comment")
+		}
+
+		issueKey, ok, err := g.trustedIssueKey(ctx, root)
+		if err != nil || !ok {
+			return nil, err
+		}
+
+		return &ThreadFeedback{
+			Owner:     owner,
+			Repo:      repo,
+			User:      event.GetSender().GetLogin(),
+			IssueKey:  issueKey,
+			CommentID: comment.GetID(),
+			Reply:     comment.GetBody(),
+		}, nil
+	case GITHUB_EVENT_REVIEW_THREAD:
+		var event PullRequestReviewThreadEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, errors.Wrap(err, "failed to parse review thread event")
+		}
+
+		if event.Action == nil || *event.Action != "resolved" || event.Thread == nil || len(event.Thread.Comments) == 0 {
+			return nil, nil
+		}
+
+		issueKey, ok, err := g.trustedIssueKey(ctx, event.Thread.Comments[0])
+		if err != nil || !ok {
+			return nil, err
+		}
+
+		return &ThreadFeedback{
+			Owner:    event.Repo.GetOwner().GetLogin(),
+			Repo:     event.Repo.GetName(),
+			User:     event.Sender.GetLogin(),
+			IssueKey: issueKey,
+			Resolved: true,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// CanWrite checks if the given user has write permission in the repository
+func (g *Github) CanWrite(ctx context.Context, owner string, repo string, user string) (bool, error) {
+	level, _, err := g.client.Repositories.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get user permission")
+	}
+
+	switch level.GetPermission() {
+	case "admin", "write":
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// trustedIssueKey returns the key of the issue behind the given thread comment, only the comments
+// written by the sqpr user are trusted since anyone can paste a marker to dismiss another issue
+func (g *Github) trustedIssueKey(ctx context.Context, comment *github.PullRequestComment) (string, bool, error) {
+	login, err := g.Login(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	if comment.GetUser().GetLogin() != login {
+		return "", false, nil
+	}
+
+	key, ok := issueKeyFor(comment.GetBody())
+
+	return key, ok, nil
+}
+
+// issueKeyFor returns the key of the issue behind the given comment body
+func issueKeyFor(body string) (string, bool) {
+	for _, commentMarker := range findMarkers(body) {
+		if key, ok := markerKey(commentMarker, MARKER_ISSUE); ok {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// ReplyTransition returns the transition of the longest keyword the reply starts with, empty when none matches
+func ReplyTransition(reply string, keywords map[string]string) string {
+	reply = strings.ToLower(strings.TrimSpace(reply))
+
+	match := ""
+	transition := ""
+	for keyword, keywordTransition := range keywords {
+		keyword = strings.ToLower(keyword)
+		if strings.HasPrefix(reply, keyword) && len(keyword) > len(match) {
+			match = keyword
+			transition = keywordTransition
+		}
+	}
+
+	return transition
+}
@@ -0,0 +1,157 @@
+package scm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v41/github"
+	"github.com/migueleliasweb/go-github-mock/src/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithubThreadFeedbackForReply(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetUser, github.User{Login: github.String("sqpr-bot")}),
+		mock.WithRequestMatch(
+			mock.GetReposPullsCommentsByOwnerByRepoByCommentId,
+			github.PullRequestComment{
+				ID:   github.Int64(90),
+				User: &github.User{Login: github.String("sqpr-bot")},
+				Body: github.String("My message\n\n<!-- sqpr:issue:AX2GHjk1-Wk2ioy15Nrv -->"),
+			},
+		),
+	)
+
+	gh := &Github{client: github.NewClient(mockedHTTPClient)}
+
+	payload := []byte(`{
+		"action": "created",
+		"comment": {"id": 91, "in_reply_to_id": 90, "body": "False positive, the value is validated before"},
+		"repository": {"name": "sonarqube-pr-issues", "owner": {"login": "herlon214"}},
+		"sender": {"login": "dev", "type": "User"}
+	}`)
+
+	feedback, err := gh.ThreadFeedbackFor(context.Background(), GITHUB_EVENT_REVIEW_COMMENT, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, &ThreadFeedback{
+		Owner:     "herlon214",
+		Repo:      "sonarqube-pr-issues",
+		User:      "dev",
+		IssueKey:  "AX2GHjk1-Wk2ioy15Nrv",
+		CommentID: 91,
+		Reply:     "False positive, the value is validated before",
+	}, feedback)
+}
+
+func TestGithubThreadFeedbackForUnrelatedReply(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetUser, github.User{Login: github.String("sqpr-bot")}),
+		mock.WithRequestMatch(
+			mock.GetReposPullsCommentsByOwnerByRepoByCommentId,
+			github.PullRequestComment{ID: github.Int64(90), User: &github.User{Login: github.String("dev")}, Body: github.String("Can you rename it?")},
+		),
+	)
+
+	gh := &Github{client: github.NewClient(mockedHTTPClient)}
+
+	// Reply to a comment that isn't an issue
+	payload := []byte(`{"action": "created", "comment": {"id": 91, "in_reply_to_id": 90, "body": "Sure"}, "repository": {"name": "repo", "owner": {"login": "owner"}}, "sender": {"login": "dev", "type": "User"}}`)
+	feedback, err := gh.ThreadFeedbackFor(context.Background(), GITHUB_EVENT_REVIEW_COMMENT, payload)
+	assert.NoError(t, err)
+	assert.Nil(t, feedback)
+
+	// Comment that isn't a reply
+	payload = []byte(`{"action": "created", "comment": {"id": 92, "body": "Looks good"}, "repository": {"name": "repo", "owner": {"login": "owner"}}, "sender": {"login": "dev", "type": "User"}}`)
+	feedback, err = gh.ThreadFeedbackFor(context.Background(), GITHUB_EVENT_REVIEW_COMMENT, payload)
+	assert.NoError(t, err)
+	assert.Nil(t, feedback)
+}
+
+func TestGithubThreadFeedbackForResolvedThread(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetUser, github.User{Login: github.String("sqpr-bot")}),
+	)
+
+	gh := &Github{client: github.NewClient(mockedHTTPClient)}
+
+	payload := []byte(`{
+		"action": "resolved",
+		"thread": {"node_id": "PRRT_1", "comments": [{"id": 90, "user": {"login": "sqpr-bot"}, "body": "My message\n\n<!-- sqpr:issue:AX2GHjk1-Wk2ioy15Nrv -->"}]},
+		"repository": {"name": "sonarqube-pr-issues", "owner": {"login": "herlon214"}},
+		"sender": {"login": "dev", "type": "User"}
+	}`)
+
+	feedback, err := gh.ThreadFeedbackFor(context.Background(), GITHUB_EVENT_REVIEW_THREAD, payload)
+	assert.NoError(t, err)
+	assert.True(t, feedback.Resolved)
+	assert.Equal(t, "AX2GHjk1-Wk2ioy15Nrv", feedback.IssueKey)
+	assert.Equal(t, "dev", feedback.User)
+
+	// Unresolved threads are ignored
+	feedback, err = gh.ThreadFeedbackFor(context.Background(), GITHUB_EVENT_REVIEW_THREAD, []byte(`{"action": "unresolved"}`))
+	assert.NoError(t, err)
+	assert.Nil(t, feedback)
+}
+
+func TestGithubThreadFeedbackForMarkerOfSomeoneElse(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(mock.GetUser, github.User{Login: github.String("sqpr-bot")}),
+		mock.WithRequestMatch(
+			mock.GetReposPullsCommentsByOwnerByRepoByCommentId,
+			github.PullRequestComment{
+				ID:   github.Int64(90),
+				User: &github.User{Login: github.String("dev")},
+				Body: github.String("Pasted\n\n<!-- sqpr:issue:AX2GHjk1-Wk2ioy15Nrv -->"),
+			},
+		),
+	)
+
+	gh := &Github{client: github.NewClient(mockedHTTPClient)}
+
+	// Reply to a thread started by someone else with a pasted marker
+	payload := []byte(`{"action": "created", "comment": {"id": 91, "in_reply_to_id": 90, "body": "/sqpr falsepositive"}, "repository": {"name": "repo", "owner": {"login": "owner"}}, "sender": {"login": "dev", "type": "User"}}`)
+	feedback, err := gh.ThreadFeedbackFor(context.Background(), GITHUB_EVENT_REVIEW_COMMENT, payload)
+	assert.NoError(t, err)
+	assert.Nil(t, feedback)
+
+	// Resolved thread started by someone else with a pasted marker
+	payload = []byte(`{
+		"action": "resolved",
+		"thread": {"node_id": "PRRT_1", "comments": [{"id": 90, "user": {"login": "dev"}, "body": "Pasted\n\n<!-- sqpr:issue:AX2GHjk1-Wk2ioy15Nrv -->"}]},
+		"repository": {"name": "repo", "owner": {"login": "owner"}},
+		"sender": {"login": "dev", "type": "User"}
+	}`)
+	feedback, err = gh.ThreadFeedbackFor(context.Background(), GITHUB_EVENT_REVIEW_THREAD, payload)
+	assert.NoError(t, err)
+	assert.Nil(t, feedback)
+}
+
+func TestGithubCanWrite(t *testing.T) {
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetReposCollaboratorsPermissionByOwnerByRepoByUsername,
+			github.RepositoryPermissionLevel{Permission: github.String("write")},
+			github.RepositoryPermissionLevel{Permission: github.String("read")},
+		),
+	)
+
+	gh := &Github{client: github.NewClient(mockedHTTPClient)}
+
+	canWrite, err := gh.CanWrite(context.Background(), "owner", "repo", "dev")
+	assert.NoError(t, err)
+	assert.True(t, canWrite)
+
+	canWrite, err = gh.CanWrite(context.Background(), "owner", "repo", "visitor")
+	assert.NoError(t, err)
+	assert.False(t, canWrite)
+}
+
+func TestReplyTransition(t *testing.T) {
+	keywords := map[string]string{"false positive": "falsepositive", "won't fix": "wontfix", "accept": "accept", "accept risk": "wontfix"}
+
+	assert.Equal(t, "falsepositive", ReplyTransition("  False positive, the value is validated", keywords))
+	assert.Equal(t, "wontfix", ReplyTransition("Won't fix: legacy code", keywords))
+	assert.Equal(t, "wontfix", ReplyTransition("accept risk", keywords))
+	assert.Equal(t, "accept", ReplyTransition("Accepted", keywords))
+	assert.Equal(t, "", ReplyTransition("This is not a false positive", keywords))
+}
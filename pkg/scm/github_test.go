@@ -3,8 +3,12 @@ package scm
 import (
 	"context"
 	_ "embed"
+	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/go-github/v41/github"
@@ -26,6 +30,29 @@ func TestNewGithub(t *testing.T) {
 	assert.NotNil(t, gh)
 }
 
+func TestNewGithubWithTransport(t *testing.T) {
+	ctx := context.Background()
+
+	var authorization string
+	rt := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		authorization = req.Header.Get("Authorization")
+
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`)), Header: http.Header{}}, nil
+	})
+
+	gh := NewGithub(ctx, sonarqube.New("", ""), "mytoken", WithTransport(rt))
+
+	_, _, err := gh.client.PullRequests.Get(ctx, "herlon214", "sonarqube-pr-issues", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer mytoken", authorization)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func TestParseGithubDiff(t *testing.T) {
 	fileDiffs, err := diff.ParseMultiFileDiff([]byte(RawPrDiff))
 	assert.NoError(t, err)
@@ -72,7 +99,7 @@ func TestGithubPublishIssuesReviewWrongSonarDiffLine(t *testing.T) {
 		},
 	}
 
-	err := gh.PublishIssuesReviewFor(ctx, issues, pr, true)
+	_, err := gh.PublishIssuesReviewFor(ctx, issues, pr, true)
 	assert.Equal(t, "failed to find relevant issues", err.Error())
 }
 
@@ -94,6 +121,8 @@ func TestGithubPublishIssuesReviewCorrectSonarDiffLine(t *testing.T) {
 
 				assert.Equal(t, `{"body":":wave: Hey, I added 1 comments about your changes, please take a look :slightly_smiling_face:","event":"REQUEST_CHANGES","comments":[{"path":"pkg/scm/github.go","body":":bug::bangbang: CRITICAL: My message ([go:S1234](root/coding_rules?open=go:S1234&rule_key=go:S1234))","side":"RIGHT","line":61}]}
 `, string(body))
+
+				w.Write([]byte(`{"id":80,"html_url":"https://github.com/herlon214/sonarqube-pr-issues/pull/3#pullrequestreview-80"}`))
 			}),
 		),
 	)
@@ -119,12 +148,453 @@ func TestGithubPublishIssuesReviewCorrectSonarDiffLine(t *testing.T) {
 			Message:   "My message",
 			Line:      61,
 		},
+		{
+			Project:   "myproject",
+			Component: "myproject:pkg/my_file.go",
+			Severity:  "CRITICAL",
+			Type:      "BUG",
+			Rule:      "go:S1234",
+			Key:       "AX2GHjk1-Wk2ioy15Nrv",
+			Message:   "Outside of the diff",
+			Line:      10,
+		},
+	}
+
+	review, err := gh.PublishIssuesReviewFor(ctx, issues, pr, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/herlon214/sonarqube-pr-issues/pull/3#pullrequestreview-80", review.URL)
+	assert.Equal(t, []string{"AX2GHjk1-Wk2ioy15Nrv"}, review.Skipped)
+}
+
+func TestGithubPublishIssuesReviewIssueComments(t *testing.T) {
+	ctx := context.Background()
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(RawPrDiff))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Contains(t, string(body), `\n\n<!-- sqpr:issue:AX2GHjk1-Wk2ioy15Nrv -->"`)
+
+				w.Write([]byte(`{"id":80,"html_url":"https://github.com/herlon214/sonarqube-pr-issues/pull/3#pullrequestreview-80"}`))
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetReposPullsReviewsCommentsByOwnerByRepoByPullNumberByReviewId,
+			[]*github.PullRequestComment{
+				{
+					Body:    github.String("My message\n\n<!-- sqpr:issue:AX2GHjk1-Wk2ioy15Nrv -->"),
+					HTMLURL: github.String("https://github.com/herlon214/sonarqube-pr-issues/pull/3#discussion_r90"),
+				},
+				{
+					Body:    github.String(":link: Secondary location"),
+					HTMLURL: github.String("https://github.com/herlon214/sonarqube-pr-issues/pull/3#discussion_r91"),
+				},
+			},
+		),
+	)
+
+	gh := &Github{
+		sonar:  sonarqube.New("root", "key"),
+		client: github.NewClient(mockedHTTPClient),
+	}
+
+	pr := &sonarqube.PullRequest{
+		Key:    "3",
+		Branch: "feat/newtest",
+		URL:    "https://github.com/herlon214/sonarqube-pr-issues/pull/3",
+	}
+
+	issues := []sonarqube.Issue{
+		{
+			Key:       "AX2GHjk1-Wk2ioy15Nrv",
+			Project:   "myproject",
+			Component: "myproject:pkg/scm/github.go",
+			Severity:  "CRITICAL",
+			Type:      "BUG",
+			Rule:      "go:S1234",
+			Message:   "My message",
+			Line:      61,
+		},
+	}
+
+	review, err := gh.PublishIssuesReviewFor(ctx, issues, pr, true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"AX2GHjk1-Wk2ioy15Nrv": "https://github.com/herlon214/sonarqube-pr-issues/pull/3#discussion_r90",
+	}, review.IssueComments)
+}
+
+func TestGithubPublishSummary(t *testing.T) {
+	ctx := context.Background()
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"component":{"key":"myproject","measures":[{"metric":"new_coverage","period":{"index":1,"value":"50.0"}}]}}`))
+	}))
+	defer svr.Close()
+
+	pr := &sonarqube.PullRequest{
+		Key:    "3",
+		Branch: "feat/newtest",
+		URL:    "https://github.com/herlon214/sonarqube-pr-issues/pull/3",
+	}
+	summary := ":bar_chart: Sonarqube measures of this PR\n\n| Metric | This PR |\n|---|---|\n| Coverage | 50.0% |\n\n<!-- sqpr:summary:3 -->"
+
+	// First analysis
+	created := 0
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("sqpr-bot")},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			[]github.IssueComment{
+				{ID: github.Int64(10), Body: github.String("LGTM")},
+				// Quoted by someone else, it isn't updated
+				{ID: github.Int64(12), Body: github.String("> old measures\n> <!-- sqpr:summary:3 -->"), User: &github.User{Login: github.String("someone")}},
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var comment github.IssueComment
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&comment))
+				assert.Equal(t, summary, comment.GetBody())
+				created++
+				w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+
+	gh := NewGithub(ctx, sonarqube.New(svr.URL, "key"), "mytoken", WithSummaryMeasures([]string{"new_coverage"}))
+	gh.client = github.NewClient(mockedHTTPClient)
+
+	assert.NoError(t, gh.PublishSummaryFor(ctx, "myproject", pr))
+	assert.Equal(t, 1, created)
+
+	// Later analyses update the same comment
+	edited := 0
+	mockedHTTPClient = mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("sqpr-bot")},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposIssuesCommentsByOwnerByRepoByIssueNumber,
+			[]github.IssueComment{
+				{ID: github.Int64(10), Body: github.String("LGTM")},
+				{ID: github.Int64(12), Body: github.String("> old measures\n> <!-- sqpr:summary:3 -->"), User: &github.User{Login: github.String("someone")}},
+				{ID: github.Int64(11), Body: github.String("old measures\n\n<!-- sqpr:summary:3 -->"), User: &github.User{Login: github.String("sqpr-bot")}},
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PatchReposIssuesCommentsByOwnerByRepoByCommentId,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var comment github.IssueComment
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&comment))
+				assert.Equal(t, summary, comment.GetBody())
+				assert.True(t, strings.HasSuffix(r.URL.Path, "/issues/comments/11"))
+				edited++
+				w.Write(mock.MustMarshal(comment))
+			}),
+		),
+	)
+	gh.client = github.NewClient(mockedHTTPClient)
+
+	assert.NoError(t, gh.PublishSummaryFor(ctx, "myproject", pr))
+	assert.Equal(t, 1, edited)
+
+	// Disabled
+	gh = NewGithub(ctx, sonarqube.New(svr.URL, "key"), "mytoken")
+	gh.client = github.NewClient(mock.NewMockedHTTPClient())
+	assert.NoError(t, gh.PublishSummaryFor(ctx, "myproject", pr))
+}
+
+func TestGithubPublishIssuesReviewSecondaryLocations(t *testing.T) {
+	ctx := context.Background()
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept") == "application/vnd.github.v3.diff" {
+					w.Write([]byte(RawPrDiff))
+
+					return
+				}
+
+				w.Write(mock.MustMarshal(github.PullRequest{Head: &github.PullRequestBranch{SHA: github.String("abc123")}}))
+			}),
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+
+				assert.Equal(t, `{"body":":wave: Hey, I added 2 comments about your changes, please take a look :slightly_smiling_face:","event":"REQUEST_CHANGES","comments":[{"path":"pkg/scm/github.go","body":":bug::bangbang: CRITICAL: My message ([go:S1234](root/coding_rules?open=go:S1234&rule_key=go:S1234))\n\n1. [pkg/scm/github.go:58](https://github.com/herlon214/sonarqube-pr-issues/blob/abc123/pkg/scm/github.go#L58) — Assigned here\n2. [pkg/other.go:3](https://github.com/herlon214/sonarqube-pr-issues/blob/abc123/pkg/other.go#L3)","side":"RIGHT","line":61},{"path":"pkg/scm/github.go","body":":link: Secondary location of [pkg/scm/github.go:61](https://github.com/herlon214/sonarqube-pr-issues/blob/abc123/pkg/scm/github.go#L61): :bug::bangbang: CRITICAL: My message ([go:S1234](root/coding_rules?open=go:S1234&rule_key=go:S1234))\n\n> Assigned here","side":"RIGHT","line":58}]}
+`, string(body))
+			}),
+		),
+	)
+
+	gh := &Github{
+		sonar:                     sonarqube.New("root", "key"),
+		client:                    github.NewClient(mockedHTTPClient),
+		secondaryLocationComments: true,
+	}
+
+	pr := &sonarqube.PullRequest{
+		Key:    "3",
+		Branch: "feat/newtest",
+		URL:    "https://github.com/herlon214/sonarqube-pr-issues/pull/3",
+	}
+
+	issues := []sonarqube.Issue{
+		{
+			Project:   "myproject",
+			Component: "myproject:pkg/scm/github.go",
+			Severity:  "CRITICAL",
+			Type:      "BUG",
+			Rule:      "go:S1234",
+			Message:   "My message",
+			Line:      61,
+			Flows: []sonarqube.Flow{
+				{Locations: []sonarqube.Location{{Component: "myproject:pkg/scm/github.go", TextRange: sonarqube.TextRange{StartLine: 58}, Message: "Assigned here"}}},
+				{Locations: []sonarqube.Location{{Component: "myproject:pkg/other.go", TextRange: sonarqube.TextRange{StartLine: 3}}}},
+			},
+		},
+	}
+
+	_, err := gh.PublishIssuesReviewFor(ctx, issues, pr, true)
+	assert.NoError(t, err)
+}
+
+func TestGithubPublishHotspotsReviewSkipsPublished(t *testing.T) {
+	ctx := context.Background()
+
+	reviews := 0
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(RawPrDiff))
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("sqpr-bot")},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposPullsCommentsByOwnerByRepoByPullNumber,
+			[]github.PullRequestComment{
+				{Body: github.String("Old hotspot\n\n<!-- sqpr:hotspot:published-key -->"), User: &github.User{Login: github.String("sqpr-bot")}},
+				// Pasted by someone else, it doesn't stop the hotspot from being published
+				{Body: github.String("Nothing to see\n\n<!-- sqpr:hotspot:new-key -->"), User: &github.User{Login: github.String("someone")}},
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reviews++
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+
+				assert.Equal(t, `{"body":":closed_lock_with_key: Hey, I found 1 security hotspots in your changes, please review them :slightly_smiling_face:","event":"COMMENT","comments":[{"path":"pkg/scm/github.go","body":":red_circle: Security Hotspot HIGH (sql-injection): My message ([go:S2077](root/coding_rules?open=go:S2077&rule_key=go:S2077)) - [review this hotspot](root/security_hotspots?id=myproject&pullRequest=3&hotspots=new-key)\n\n<!-- sqpr:hotspot:new-key -->","side":"RIGHT","line":61}]}
+`, string(body))
+			}),
+		),
+	)
+
+	gh := &Github{
+		sonar:  sonarqube.New("root", "key"),
+		client: github.NewClient(mockedHTTPClient),
+	}
+
+	pr := &sonarqube.PullRequest{
+		Key:    "3",
+		Branch: "feat/newtest",
+		URL:    "https://github.com/herlon214/sonarqube-pr-issues/pull/3",
+	}
+
+	hotspot := sonarqube.Hotspot{
+		Project:                  "myproject",
+		Component:                "myproject:pkg/scm/github.go",
+		SecurityCategory:         "sql-injection",
+		VulnerabilityProbability: "HIGH",
+		RuleKey:                  "go:S2077",
+		Message:                  "My message",
+		Line:                     61,
+	}
+	published := hotspot
+	published.Key = "published-key"
+	outsideDiff := hotspot
+	outsideDiff.Key = "outside-key"
+	outsideDiff.Line = 500
+	hotspot.Key = "new-key"
+
+	err := gh.PublishHotspotsReviewFor(ctx, []sonarqube.Hotspot{published, outsideDiff, hotspot}, pr)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reviews)
+}
+
+func TestGithubPublishCoverageReview(t *testing.T) {
+	ctx := context.Background()
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// go.mod and go.sum aren't indexed by Sonarqube
+		if r.URL.Query().Get("key") != "myproject:pkg/scm/github.go" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"msg":"Component key '` + r.URL.Query().Get("key") + `' not found"}]}`))
+
+			return
+		}
+
+		w.Write([]byte(`{"sources":[{"line":10,"lineHits":0},{"line":60,"lineHits":0},{"line":61,"lineHits":0},{"line":62,"lineHits":0},{"line":63,"lineHits":1},{"line":65,"lineHits":0},{"line":70,"lineHits":1,"conditions":2,"coveredConditions":1}]}`))
+	}))
+	defer svr.Close()
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(RawPrDiff))
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("sqpr-bot")},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposPullsCommentsByOwnerByRepoByPullNumber,
+			[]github.PullRequestComment{
+				{Body: github.String(":umbrella: Line 3 is not covered by tests\n\n<!-- sqpr:coverage:pkg/other.go#L3-L3 -->"), User: &github.User{Login: github.String("sqpr-bot")}},
+			},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+
+				assert.Equal(t, `{"body":":umbrella: Hey, I found 2 blocks of new code not covered by tests, please take a look :slightly_smiling_face:","event":"COMMENT","comments":[{"path":"pkg/scm/github.go","body":":umbrella: Lines 60 to 62 are not covered by tests\n\n<!-- sqpr:coverage:pkg/scm/github.go#L60-L62 -->","start_side":"RIGHT","side":"RIGHT","start_line":60,"line":62},{"path":"pkg/scm/github.go","body":":umbrella: Line 65 is not covered by tests\n\n<!-- sqpr:coverage:pkg/scm/github.go#L65-L65 -->","side":"RIGHT","line":65}]}
+`, string(body))
+			}),
+		),
+	)
+
+	gh := &Github{
+		sonar:  sonarqube.New(svr.URL, "key"),
+		client: github.NewClient(mockedHTTPClient),
+	}
+
+	pr := &sonarqube.PullRequest{
+		Key:    "3",
+		Branch: "feat/newtest",
+		URL:    "https://github.com/herlon214/sonarqube-pr-issues/pull/3",
+	}
+
+	err := gh.PublishCoverageReviewFor(ctx, "myproject", pr, 3)
+	assert.NoError(t, err)
+}
+
+func TestGithubPublishDuplicationsReview(t *testing.T) {
+	ctx := context.Background()
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// go.mod and go.sum aren't indexed by Sonarqube
+		if r.URL.Query().Get("key") != "myproject:pkg/scm/github.go" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"msg":"Component key '` + r.URL.Query().Get("key") + `' not found"}]}`))
+
+			return
+		}
+
+		w.Write([]byte(`{"duplications":[{"blocks":[{"from":55,"size":8,"_ref":"1"},{"from":10,"size":8,"_ref":"2"},{"from":3,"size":8,"_ref":"3"}]},{"blocks":[{"from":200,"size":5,"_ref":"1"},{"from":20,"size":5,"_ref":"2"}]}],"files":{"1":{"key":"myproject:pkg/scm/github.go","project":"myproject"},"2":{"key":"myproject:pkg/scm/other.go","project":"myproject"},"3":{"key":"otherproject:main.go","project":"otherproject"}}}`))
+	}))
+	defer svr.Close()
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept") == "application/vnd.github.v3.diff" {
+					w.Write([]byte(RawPrDiff))
+
+					return
+				}
+
+				w.Write(mock.MustMarshal(github.PullRequest{Head: &github.PullRequestBranch{SHA: github.String("abc123")}}))
+			}),
+		),
+		mock.WithRequestMatch(
+			mock.GetUser,
+			github.User{Login: github.String("sqpr-bot")},
+		),
+		mock.WithRequestMatch(
+			mock.GetReposPullsCommentsByOwnerByRepoByPullNumber,
+			[]github.PullRequestComment{},
+		),
+		mock.WithRequestMatchHandler(
+			mock.PostReposPullsReviewsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+
+				assert.Equal(t, `{"body":":busts_in_silhouette: Hey, I found 1 duplicated blocks in your changes, please take a look :slightly_smiling_face:","event":"COMMENT","comments":[{"path":"pkg/scm/github.go","body":":busts_in_silhouette: Lines 55 to 62 are duplicated in:\n- [pkg/scm/other.go:10-17](https://github.com/herlon214/sonarqube-pr-issues/blob/abc123/pkg/scm/other.go#L10-L17)\n- `+"`main.go`"+` L3-L10 (project otherproject)\n\n<!-- sqpr:duplication:pkg/scm/github.go#L55-L62 -->","start_side":"RIGHT","side":"RIGHT","start_line":60,"line":62}]}
+`, string(body))
+			}),
+		),
+	)
+
+	gh := &Github{
+		sonar:  sonarqube.New(svr.URL, "key"),
+		client: github.NewClient(mockedHTTPClient),
 	}
 
-	err := gh.PublishIssuesReviewFor(ctx, issues, pr, true)
+	pr := &sonarqube.PullRequest{
+		Key:    "3",
+		Branch: "feat/newtest",
+		URL:    "https://github.com/herlon214/sonarqube-pr-issues/pull/3",
+	}
+
+	err := gh.PublishDuplicationsReviewFor(ctx, "myproject", pr)
 	assert.NoError(t, err)
 }
 
+func TestAddedLines(t *testing.T) {
+	fileDiffs, err := diff.ParseMultiFileDiff([]byte(RawPrDiff))
+	assert.NoError(t, err)
+
+	// go.mod adds a single line after 3 context lines
+	added := addedLines(fileDiffs[0].Hunks)
+	assert.Equal(t, map[int]bool{24: true}, added)
+}
+
+func TestFindMarkers(t *testing.T) {
+	body := "Some text\n\n" + marker(MARKER_HOTSPOT, "AX2GHjk1-Wk2ioy15Nrx") + "\n<!-- not a marker -->"
+
+	assert.Equal(t, []string{"<!-- sqpr:hotspot:AX2GHjk1-Wk2ioy15Nrx -->"}, findMarkers(body))
+}
+
+func TestMarkerKey(t *testing.T) {
+	key, ok := markerKey(marker(MARKER_ISSUE, "AX2GHjk1-Wk2ioy15Nrv"), MARKER_ISSUE)
+	assert.True(t, ok)
+	assert.Equal(t, "AX2GHjk1-Wk2ioy15Nrv", key)
+
+	_, ok = markerKey(marker(MARKER_HOTSPOT, "AX2GHjk1-Wk2ioy15Nrv"), MARKER_ISSUE)
+	assert.False(t, ok)
+}
+
 func TestParsePullRequestUrl(t *testing.T) {
 	ghPath, err := parseGithubPath("https://github.com/herlon214/sonarqube-pr-issues/pull/2")
 	assert.NoError(t, err)
@@ -147,3 +617,83 @@ func TestParsePathEmpty(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, ghPath)
 }
+
+func TestGithubCheckCredentials(t *testing.T) {
+	ctx := context.Background()
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatch(
+			mock.GetRateLimit,
+			map[string]interface{}{"resources": github.RateLimits{Core: &github.Rate{Limit: 5000, Remaining: 4999}}},
+		),
+	)
+
+	gh := &Github{
+		sonar:  sonarqube.New("root", "key"),
+		client: github.NewClient(mockedHTTPClient),
+	}
+
+	rate, err := gh.CheckCredentials(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 4999, rate.Remaining)
+}
+
+func TestGithubPullRequestDiffUsesTheNewNames(t *testing.T) {
+	ctx := context.Background()
+
+	rawDiff := `diff --git a/pkg/old_name.go b/pkg/new_name.go
+similarity index 90%
+rename from pkg/old_name.go
+rename to pkg/new_name.go
+index 5d90f4b..9521466 100644
+--- a/pkg/old_name.go
++++ b/pkg/new_name.go
+@@ -1,3 +1,4 @@
+ package pkg
+ 
++var unused = 1
+ var used = 2
+diff --git a/pkg/added.go b/pkg/added.go
+new file mode 100644
+index 0000000..9521466
+--- /dev/null
++++ b/pkg/added.go
+@@ -0,0 +1,2 @@
++package pkg
++
+diff --git a/pkg/removed.go b/pkg/removed.go
+deleted file mode 100644
+index 5d90f4b..0000000
+--- a/pkg/removed.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package pkg
+-
+`
+
+	mockedHTTPClient := mock.NewMockedHTTPClient(
+		mock.WithRequestMatchHandler(
+			mock.GetReposPullsByOwnerByRepoByPullNumber,
+			http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte(rawDiff))
+			}),
+		),
+	)
+
+	gh := NewGithub(ctx, sonarqube.New("root", "key"), "mytoken")
+	gh.client = github.NewClient(mockedHTTPClient)
+
+	diffMap, err := gh.pullRequestDiff(ctx, &GithubPath{Owner: "herlon214", Repo: "sonarqube-pr-issues"}, 3)
+	assert.NoError(t, err)
+
+	files := make([]string, 0, len(diffMap))
+	for file := range diffMap {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	assert.Equal(t, []string{"pkg/added.go", "pkg/new_name.go"}, files)
+	assert.True(t, isLineInHunks(diffMap["pkg/new_name.go"], 3))
+	assert.True(t, isLineInHunks(diffMap["pkg/new_name.go"], 4))
+	assert.False(t, isLineInHunks(diffMap["pkg/new_name.go"], 5))
+	assert.True(t, isLineInHunks(diffMap["pkg/added.go"], 1))
+}
@@ -0,0 +1,51 @@
+package scm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	MARKER_HOTSPOT     = "hotspot"
+	MARKER_COVERAGE    = "coverage"
+	MARKER_DUPLICATION = "duplication"
+	MARKER_ISSUE       = "issue"
+	MARKER_SUMMARY     = "summary"
+)
+
+var markerRegexp = regexp.MustCompile(`<!-- sqpr:[a-z]+:\S+ -->`)
+
+// marker creates a hidden comment that identifies the Sonarqube entity behind a comment
+func marker(kind string, key string) string {
+	return fmt.Sprintf("<!-- sqpr:%s:%s -->", kind, key)
+}
+
+// findMarkers returns all the markers found in the given comment body
+func findMarkers(body string) []string {
+	return markerRegexp.FindAllString(body, -1)
+}
+
+// countMarkers counts how many of the given markers are of the given kind
+func countMarkers(markers map[string]bool, kind string) int {
+	prefix := fmt.Sprintf("<!-- sqpr:%s:", kind)
+
+	count := 0
+	for item := range markers {
+		if strings.HasPrefix(item, prefix) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// markerKey returns the key of the given marker when it's of the given kind
+func markerKey(item string, kind string) (string, bool) {
+	prefix := fmt.Sprintf("<!-- sqpr:%s:", kind)
+	if !strings.HasPrefix(item, prefix) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(item, prefix), " -->"), true
+}
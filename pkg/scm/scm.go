@@ -3,9 +3,47 @@ package scm
 import (
 	"context"
 
+	"github.com/pkg/errors"
+
 	"github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
 )
 
+// ErrNoRelevantIssues is returned when none of the issues can be commented in the PR diff
+var ErrNoRelevantIssues = errors.New("failed to find relevant issues")
+
+// SCM publishes the Sonarqube findings in the PRs, providers accept a WithTransport option
+// so they share the same proxy and TLS configuration with the Sonarqube client
 type SCM interface {
-	PublishIssuesReviewFor(ctx context.Context, issues []sonarqube.Issue, pr *sonarqube.PullRequest, requestChanges bool) error
+	PublishIssuesReviewFor(ctx context.Context, issues []sonarqube.Issue, pr *sonarqube.PullRequest, requestChanges bool) (*Review, error)
+	PublishHotspotsReviewFor(ctx context.Context, hotspots []sonarqube.Hotspot, pr *sonarqube.PullRequest) error
+	PublishCoverageReviewFor(ctx context.Context, project string, pr *sonarqube.PullRequest, maxComments int) error
+	PublishDuplicationsReviewFor(ctx context.Context, project string, pr *sonarqube.PullRequest) error
+	PublishSummaryFor(ctx context.Context, project string, pr *sonarqube.PullRequest) error
+}
+
+// Review is a review published in the PR
+type Review struct {
+	// URL links to the review in the SCM
+	URL string
+	// IssueComments links the Sonarqube issue keys to their comment in the review
+	IssueComments map[string]string
+	// Skipped has the keys of the issues left out as they aren't part of the PR diff
+	Skipped []string
+}
+
+// Published returns the given issues that weren't skipped, the ones commented in the review
+func (r *Review) Published(issues []sonarqube.Issue) []sonarqube.Issue {
+	skipped := make(map[string]bool, len(r.Skipped))
+	for _, key := range r.Skipped {
+		skipped[key] = true
+	}
+
+	published := make([]sonarqube.Issue, 0, len(issues))
+	for _, issue := range issues {
+		if !skipped[issue.Key] {
+			published = append(published, issue)
+		}
+	}
+
+	return published
 }
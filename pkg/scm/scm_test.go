@@ -0,0 +1,15 @@
+package scm
+
+import (
+	"testing"
+
+	"github.com/herlon214/sonarqube-pr-issues/pkg/sonarqube"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewPublished(t *testing.T) {
+	review := &Review{Skipped: []string{"outside"}}
+
+	published := review.Published([]sonarqube.Issue{{Key: "inside"}, {Key: "outside"}})
+	assert.Equal(t, []sonarqube.Issue{{Key: "inside"}}, published)
+}
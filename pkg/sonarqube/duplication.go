@@ -0,0 +1,42 @@
+package sonarqube
+
+import (
+	"fmt"
+	"strings"
+)
+
+type DuplicationBlock struct {
+	From int    `json:"from"`
+	Size int    `json:"size"`
+	Ref  string `json:"_ref"`
+}
+
+type Duplication struct {
+	Blocks []DuplicationBlock `json:"blocks"`
+}
+
+type DuplicationFile struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Project string `json:"project"`
+}
+
+type Duplications struct {
+	Duplications []Duplication              `json:"duplications"`
+	Files        map[string]DuplicationFile `json:"files"`
+}
+
+// To returns the last line of the block
+func (b DuplicationBlock) To() int {
+	return b.From + b.Size - 1
+}
+
+// FilePath returns the file path by reading the key and removing the project from it
+func (f DuplicationFile) FilePath() string {
+	return strings.Replace(f.Key, fmt.Sprintf("%s:", f.Project), "", -1)
+}
+
+// File returns the file the given block belongs to
+func (d Duplications) File(block DuplicationBlock) DuplicationFile {
+	return d.Files[block.Ref]
+}
@@ -0,0 +1,100 @@
+package sonarqube
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when the searched entity doesn't exist in Sonarqube
+var ErrNotFound = errors.New("not found")
+
+// errInvalidVersion is returned when the server version can't be parsed
+var errInvalidVersion = errors.New("invalid server version")
+
+// invalidVersionError keeps why the server version couldn't be parsed, it matches errInvalidVersion
+type invalidVersionError struct {
+	cause error
+}
+
+func (e *invalidVersionError) Error() string {
+	return fmt.Sprintf("%s: %s", errInvalidVersion, e.cause)
+}
+
+func (e *invalidVersionError) Unwrap() error {
+	return e.cause
+}
+
+func (e *invalidVersionError) Is(target error) bool {
+	return target == errInvalidVersion
+}
+
+// APIError is returned when Sonarqube answers with a non successful status code
+type APIError struct {
+	StatusCode int
+	Endpoint   string
+	Messages   []string
+	// RetryAfter is how long Sonarqube asked to wait before retrying, zero when not informed
+	RetryAfter time.Duration
+}
+
+type apiErrorResponse struct {
+	Errors []struct {
+		Msg string `json:"msg"`
+	} `json:"errors"`
+}
+
+func (e *APIError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("sonarqube %s returned %d %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("sonarqube %s returned %d %s: %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode), strings.Join(e.Messages, "; "))
+}
+
+// IsAuth checks if the token is invalid or doesn't have enough permissions
+func (e *APIError) IsAuth() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// IsNotFound checks if the requested entity doesn't exist
+func (e *APIError) IsNotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// IsRateLimit checks if too many requests were sent
+func (e *APIError) IsRateLimit() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// IsServer checks if Sonarqube failed to process the request
+func (e *APIError) IsServer() bool {
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// Temporary checks if the same request may succeed later
+func (e *APIError) Temporary() bool {
+	return e.IsRateLimit() || e.IsServer()
+}
+
+// IsRetryable checks if the given error may go away by retrying,
+// API errors are only retryable when temporary and missing entities are never retryable.
+// Any other error, like a network failure, a timeout or an unreadable response from a proxy, is
+// retryable by default: it can't be told apart from a temporary one and the attempts are limited anyway.
+// Cancelled requests aren't retried as whoever cancelled them isn't waiting anymore
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+
+	return true
+}
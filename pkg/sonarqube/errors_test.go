@@ -0,0 +1,69 @@
+package sonarqube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSonarqubeAPIError(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[{"msg":"Project 'myproject' not found"}]}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	_, err := sonar.ListIssuesForPR("myproject", "3")
+	assert.Error(t, err)
+
+	var apiErr *APIError
+	assert.True(t, errors.As(errors.Wrap(err, "wrapped"), &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "/api/issues/search", apiErr.Endpoint)
+	assert.Equal(t, []string{"Project 'myproject' not found"}, apiErr.Messages)
+	assert.True(t, apiErr.IsNotFound())
+	assert.False(t, apiErr.Temporary())
+	assert.Equal(t, "sonarqube /api/issues/search returned 404 Not Found: Project 'myproject' not found", apiErr.Error())
+}
+
+func TestSonarqubeAPIErrorWithoutBody(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	_, err := sonar.ProjectPullRequests("myproject")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.True(t, apiErr.IsAuth())
+	assert.Equal(t, "sonarqube /api/project_pull_requests/list returned 401 Unauthorized", err.Error())
+}
+
+func TestAPIErrorKinds(t *testing.T) {
+	assert.True(t, (&APIError{StatusCode: http.StatusForbidden}).IsAuth())
+	assert.True(t, (&APIError{StatusCode: http.StatusTooManyRequests}).IsRateLimit())
+	assert.True(t, (&APIError{StatusCode: http.StatusTooManyRequests}).Temporary())
+	assert.True(t, (&APIError{StatusCode: http.StatusBadGateway}).IsServer())
+	assert.True(t, (&APIError{StatusCode: http.StatusBadGateway}).Temporary())
+	assert.False(t, (&APIError{StatusCode: http.StatusBadRequest}).Temporary())
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(errors.New("connection refused")))
+	assert.True(t, IsRetryable(errors.Wrap(&APIError{StatusCode: http.StatusServiceUnavailable}, "wrapped")))
+	assert.False(t, IsRetryable(errors.Wrap(&APIError{StatusCode: http.StatusUnauthorized}, "wrapped")))
+	assert.False(t, IsRetryable(errors.Wrap(ErrNotFound, "failed to find PR")))
+	assert.True(t, IsRetryable(errors.Wrap(context.DeadlineExceeded, "failed to list issues")))
+	assert.False(t, IsRetryable(errors.Wrap(context.Canceled, "failed to list issues")))
+}
@@ -0,0 +1,33 @@
+package sonarqube
+
+import (
+	"fmt"
+	"strings"
+)
+
+type TextRange struct {
+	StartLine   int `json:"startLine"`
+	EndLine     int `json:"endLine"`
+	StartOffset int `json:"startOffset"`
+	EndOffset   int `json:"endOffset"`
+}
+
+type Location struct {
+	Component string    `json:"component"`
+	TextRange TextRange `json:"textRange"`
+	Message   string    `json:"msg"`
+}
+
+type Flow struct {
+	Locations []Location `json:"locations"`
+}
+
+// FilePath returns the file path by reading the component and removing the given project from it
+func (l Location) FilePath(project string) string {
+	return strings.Replace(l.Component, fmt.Sprintf("%s:", project), "", -1)
+}
+
+// Line returns the line where the location starts
+func (l Location) Line() int {
+	return l.TextRange.StartLine
+}
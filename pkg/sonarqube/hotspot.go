@@ -0,0 +1,58 @@
+package sonarqube
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	HOTSPOT_STATUS_TO_REVIEW = "TO_REVIEW"
+)
+
+type Hotspot struct {
+	Key                      string    `json:"key"`
+	Component                string    `json:"component"`
+	Project                  string    `json:"project"`
+	SecurityCategory         string    `json:"securityCategory"`
+	VulnerabilityProbability string    `json:"vulnerabilityProbability"`
+	Status                   string    `json:"status"`
+	Line                     int       `json:"line"`
+	Message                  string    `json:"message"`
+	RuleKey                  string    `json:"ruleKey"`
+	TextRange                TextRange `json:"textRange"`
+}
+
+// MarkdownMessage creates a nice markdown message for the hotspot
+func (h Hotspot) MarkdownMessage(root string, pullRequest string) string {
+	return fmt.Sprintf(
+		`%s Security Hotspot %s (%s): %s ([%s](%s)) - [review this hotspot](%s)`,
+		h.ProbabilityEmoji(), h.VulnerabilityProbability, h.SecurityCategory, h.Message, h.RuleKey, h.RuleLink(root), h.ReviewLink(root, pullRequest),
+	)
+}
+
+// RuleLink creates the url to the given rule
+func (h Hotspot) RuleLink(root string) string {
+	return fmt.Sprintf("%s/coding_rules?open=%s&rule_key=%s", root, h.RuleKey, h.RuleKey)
+}
+
+// ReviewLink creates the url to review the hotspot in the given PR
+func (h Hotspot) ReviewLink(root string, pullRequest string) string {
+	return fmt.Sprintf("%s/security_hotspots?id=%s&pullRequest=%s&hotspots=%s", root, h.Project, pullRequest, h.Key)
+}
+
+// FilePath returns the file path by reading the component and removing the project from it
+func (h Hotspot) FilePath() string {
+	return strings.Replace(h.Component, fmt.Sprintf("%s:", h.Project), "", -1)
+}
+
+// ProbabilityEmoji creates a nice emoji for the current vulnerability probability
+func (h Hotspot) ProbabilityEmoji() string {
+	switch h.VulnerabilityProbability {
+	case "HIGH":
+		return ":red_circle:"
+	case "MEDIUM":
+		return ":orange_circle:"
+	default:
+		return ":yellow_circle:"
+	}
+}
@@ -0,0 +1,27 @@
+package sonarqube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHotspotMarkdownMessage(t *testing.T) {
+	hotspot := Hotspot{
+		Key:                      "AX2GHjk1",
+		Project:                  "myproject",
+		Component:                "myproject:pkg/db.go",
+		SecurityCategory:         "sql-injection",
+		VulnerabilityProbability: "HIGH",
+		RuleKey:                  "go:S2077",
+		Message:                  "My message",
+	}
+
+	assert.Equal(t, ":red_circle: Security Hotspot HIGH (sql-injection): My message ([go:S2077](https://my-sonar/coding_rules?open=go:S2077&rule_key=go:S2077)) - [review this hotspot](https://my-sonar/security_hotspots?id=myproject&pullRequest=3&hotspots=AX2GHjk1)", hotspot.MarkdownMessage("https://my-sonar", "3"))
+}
+
+func TestHotspotProbabilityEmojis(t *testing.T) {
+	assert.Equal(t, ":red_circle:", Hotspot{VulnerabilityProbability: "HIGH"}.ProbabilityEmoji())
+	assert.Equal(t, ":orange_circle:", Hotspot{VulnerabilityProbability: "MEDIUM"}.ProbabilityEmoji())
+	assert.Equal(t, ":yellow_circle:", Hotspot{VulnerabilityProbability: "LOW"}.ProbabilityEmoji())
+}
@@ -0,0 +1,17 @@
+package sonarqube
+
+type Hotspots struct {
+	Hotspots []Hotspot `json:"hotspots"`
+}
+
+// FilterByStatus filters the hotspots by the given status
+func (h Hotspots) FilterByStatus(status string) *Hotspots {
+	filtered := make([]Hotspot, 0)
+	for _, hotspot := range h.Hotspots {
+		if hotspot.Status == status {
+			filtered = append(filtered, hotspot)
+		}
+	}
+
+	return &Hotspots{Hotspots: filtered}
+}
@@ -6,22 +6,27 @@ import (
 )
 
 type Issue struct {
-	Severity  string   `json:"severity"`
-	Component string   `json:"component"`
-	Project   string   `json:"project"`
-	Status    string   `json:"status"`
-	Rule      string   `json:"rule"`
-	Key       string   `json:"key"`
-	Type      string   `json:"type"`
-	Tags      []string `json:"tags"`
-	Line      int      `json:"line"`
-	Message   string   `json:"message"`
-	TextRange struct {
-		StartLine   int `json:"startLine"`
-		EndLine     int `json:"endLine"`
-		StartOffset int `json:"startOffset"`
-		EndOffset   int `json:"endOffset"`
-	}
+	Severity  string    `json:"severity"`
+	Component string    `json:"component"`
+	Project   string    `json:"project"`
+	Status    string    `json:"status"`
+	Rule      string    `json:"rule"`
+	Key       string    `json:"key"`
+	Type      string    `json:"type"`
+	Tags      []string  `json:"tags"`
+	Line      int       `json:"line"`
+	Message   string    `json:"message"`
+	TextRange TextRange `json:"textRange"`
+	Flows     []Flow    `json:"flows"`
+	Impacts   []Impact  `json:"impacts"`
+	// Transitions can be applied to the issue, only listed when asked for
+	Transitions []string `json:"transitions,omitempty"`
+}
+
+// Impact is the effect of the issue in a software quality, only reported since Sonarqube 10.2
+type Impact struct {
+	SoftwareQuality string `json:"softwareQuality"`
+	Severity        string `json:"severity"`
 }
 
 // MarkdownMessage creates a nice markdown message for the issue
@@ -39,6 +44,16 @@ func (i Issue) FilePath() string {
 	return strings.Replace(i.Component, fmt.Sprintf("%s:", i.Project), "", -1)
 }
 
+// SecondaryLocations returns the locations of all the issue flows, in the order Sonarqube reports them
+func (i Issue) SecondaryLocations() []Location {
+	locations := make([]Location, 0)
+	for _, flow := range i.Flows {
+		locations = append(locations, flow.Locations...)
+	}
+
+	return locations
+}
+
 // SeverityEmoji creates a nice emoji for the current severity
 func (i Issue) SeverityEmoji() string {
 	switch i.Severity {
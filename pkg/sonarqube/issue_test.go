@@ -32,3 +32,21 @@ func TestTypeEmojis(t *testing.T) {
 	assert.Equal(t, ":thought_balloon:", Issue{Type: "SOMETHINGELSE"}.TypeEmoji())
 
 }
+
+func TestSecondaryLocations(t *testing.T) {
+	issue := Issue{
+		Project: "myproject",
+		Flows: []Flow{
+			{Locations: []Location{{Component: "myproject:pkg/a.go", TextRange: TextRange{StartLine: 3}, Message: "first"}}},
+			{Locations: []Location{{Component: "myproject:pkg/b.go", TextRange: TextRange{StartLine: 7}}}},
+		},
+	}
+
+	locations := issue.SecondaryLocations()
+	assert.Equal(t, 2, len(locations))
+	assert.Equal(t, "pkg/a.go", locations[0].FilePath(issue.Project))
+	assert.Equal(t, 3, locations[0].Line())
+	assert.Equal(t, "first", locations[0].Message)
+	assert.Equal(t, "pkg/b.go", locations[1].FilePath(issue.Project))
+	assert.Equal(t, 0, len(Issue{}.SecondaryLocations()))
+}
@@ -0,0 +1,214 @@
+package sonarqube
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultPullRequestMetrics are the metrics shown in the PR review summary
+var DefaultPullRequestMetrics = []string{
+	"new_coverage",
+	"new_duplicated_lines_density",
+	"new_violations",
+	"new_technical_debt",
+	"new_reliability_rating",
+	"new_security_rating",
+	"new_maintainability_rating",
+}
+
+// branchMetrics maps the new code metrics to the overall ones they are compared with in the target branch,
+// the counts of the new code are only comparable with the new code counts of the target branch
+var branchMetrics = map[string]string{
+	"new_coverage":                 "coverage",
+	"new_duplicated_lines_density": "duplicated_lines_density",
+	"new_reliability_rating":       "reliability_rating",
+	"new_security_rating":          "security_rating",
+	"new_maintainability_rating":   "sqale_rating",
+}
+
+var metricNames = map[string]string{
+	"new_coverage":                 "Coverage",
+	"new_duplicated_lines_density": "Duplication",
+	"new_violations":               "Issues",
+	"new_technical_debt":           "Technical debt",
+	"new_reliability_rating":       "Reliability",
+	"new_security_rating":          "Security",
+	"new_maintainability_rating":   "Maintainability",
+	"new_bugs":                     "Bugs",
+	"new_vulnerabilities":          "Vulnerabilities",
+	"new_code_smells":              "Code smells",
+}
+
+type MeasurePeriod struct {
+	Index int    `json:"index"`
+	Value string `json:"value"`
+}
+
+type Measure struct {
+	Metric  string          `json:"metric"`
+	Value   string          `json:"value"`
+	Period  *MeasurePeriod  `json:"period"`
+	Periods []MeasurePeriod `json:"periods"`
+}
+
+type Measures struct {
+	Component struct {
+		Key      string    `json:"key"`
+		Measures []Measure `json:"measures"`
+	} `json:"component"`
+}
+
+// BranchMetric returns the metric a PR metric is compared with in the target branch
+func BranchMetric(metric string) string {
+	if branchMetric, ok := branchMetrics[metric]; ok {
+		return branchMetric
+	}
+
+	return metric
+}
+
+// MeasureValue returns the measure value, new code metrics are reported in the period
+func (m Measure) MeasureValue() string {
+	if m.Value != "" {
+		return m.Value
+	}
+
+	if m.Period != nil {
+		return m.Period.Value
+	}
+
+	if len(m.Periods) > 0 {
+		return m.Periods[0].Value
+	}
+
+	return ""
+}
+
+// Get returns the value of the given metric
+func (m Measures) Get(metric string) (string, bool) {
+	for _, measure := range m.Component.Measures {
+		if measure.Metric == metric {
+			return measure.MeasureValue(), true
+		}
+	}
+
+	return "", false
+}
+
+// MarkdownTable creates a markdown table with the given metrics, comparing them with the target branch ones when given
+func (m Measures) MarkdownTable(metrics []string, target *Measures, targetBranch string) string {
+	lines := make([]string, 0, len(metrics)+2)
+	if target != nil {
+		lines = append(lines, fmt.Sprintf("| Metric | This PR | `%s` | Delta |", targetBranch), "|---|---|---|---|")
+	} else {
+		lines = append(lines, "| Metric | This PR |", "|---|---|")
+	}
+
+	for _, metric := range metrics {
+		value, ok := m.Get(metric)
+		if !ok {
+			continue
+		}
+
+		name := metricNames[metric]
+		if name == "" {
+			name = metric
+		}
+
+		if target == nil {
+			lines = append(lines, fmt.Sprintf("| %s | %s |", name, formatMeasure(metric, value)))
+
+			continue
+		}
+
+		targetValue, ok := target.Get(BranchMetric(metric))
+		if !ok {
+			lines = append(lines, fmt.Sprintf("| %s | %s | - | - |", name, formatMeasure(metric, value)))
+
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s | %s |", name, formatMeasure(metric, value), formatMeasure(metric, targetValue), formatDelta(metric, value, targetValue)))
+	}
+
+	// No measures found
+	if len(lines) == 2 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatMeasure formats the value according to the metric
+func formatMeasure(metric string, value string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	switch {
+	case strings.HasSuffix(metric, "_rating"):
+		return ratingLetter(number)
+	case strings.HasSuffix(metric, "coverage") || strings.HasSuffix(metric, "_density"):
+		return fmt.Sprintf("%.1f%%", number)
+	case strings.HasSuffix(metric, "technical_debt"):
+		return formatMinutes(int(number))
+	default:
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+}
+
+// formatDelta formats the difference between the PR and target values,
+// it's only meaningful for percentages and ratings since the other metrics are counted for the new code only
+func formatDelta(metric string, value string, targetValue string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "-"
+	}
+	targetNumber, err := strconv.ParseFloat(targetValue, 64)
+	if err != nil {
+		return "-"
+	}
+	delta := number - targetNumber
+
+	switch {
+	case strings.HasSuffix(metric, "_rating"):
+		if delta == 0 {
+			return "="
+		}
+		if delta < 0 {
+			return ":arrow_up: better"
+		}
+
+		return ":arrow_down: worse"
+	case strings.HasSuffix(metric, "coverage") || strings.HasSuffix(metric, "_density"):
+		return fmt.Sprintf("%+.1f%%", delta)
+	default:
+		return "-"
+	}
+}
+
+// ratingLetter converts the numeric rating into its letter
+func ratingLetter(rating float64) string {
+	letters := []string{"A", "B", "C", "D", "E"}
+	index := int(rating) - 1
+	if index < 0 || index >= len(letters) {
+		return strconv.FormatFloat(rating, 'f', -1, 64)
+	}
+
+	return letters[index]
+}
+
+// formatMinutes formats the given minutes the way Sonarqube shows the technical debt
+func formatMinutes(minutes int) string {
+	if minutes < 60 {
+		return fmt.Sprintf("%dmin", minutes)
+	}
+
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+
+	return fmt.Sprintf("%dh %dmin", minutes/60, minutes%60)
+}
@@ -0,0 +1,41 @@
+package sonarqube
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMeasureValue(t *testing.T) {
+	assert.Equal(t, "10", Measure{Value: "10"}.MeasureValue())
+	assert.Equal(t, "20", Measure{Period: &MeasurePeriod{Value: "20"}}.MeasureValue())
+	assert.Equal(t, "30", Measure{Periods: []MeasurePeriod{{Value: "30"}}}.MeasureValue())
+	assert.Equal(t, "", Measure{}.MeasureValue())
+}
+
+func TestMeasuresMarkdownTable(t *testing.T) {
+	var prMeasures, targetMeasures Measures
+	err := json.Unmarshal([]byte(`{"component":{"key":"myproject","measures":[{"metric":"new_coverage","period":{"index":1,"value":"82.5"}},{"metric":"new_violations","period":{"index":1,"value":"3"}},{"metric":"new_technical_debt","period":{"index":1,"value":"95"}},{"metric":"new_security_rating","period":{"index":1,"value":"2.0"}}]}}`), &prMeasures)
+	assert.NoError(t, err)
+	err = json.Unmarshal([]byte(`{"component":{"key":"myproject","measures":[{"metric":"coverage","value":"80.0"},{"metric":"violations","value":"120"},{"metric":"new_violations","period":{"index":1,"value":"5"}},{"metric":"security_rating","value":"1.0"}]}}`), &targetMeasures)
+	assert.NoError(t, err)
+
+	metrics := []string{"new_coverage", "new_duplicated_lines_density", "new_violations", "new_technical_debt", "new_security_rating"}
+
+	assert.Equal(t, "| Metric | This PR | `main` | Delta |\n"+
+		"|---|---|---|---|\n"+
+		"| Coverage | 82.5% | 80.0% | +2.5% |\n"+
+		"| Issues | 3 | 5 | - |\n"+
+		"| Technical debt | 1h 35min | - | - |\n"+
+		"| Security | B | A | :arrow_down: worse |", prMeasures.MarkdownTable(metrics, &targetMeasures, "main"))
+
+	assert.Equal(t, "| Metric | This PR |\n"+
+		"|---|---|\n"+
+		"| Coverage | 82.5% |\n"+
+		"| Issues | 3 |\n"+
+		"| Technical debt | 1h 35min |\n"+
+		"| Security | B |", prMeasures.MarkdownTable(metrics, nil, ""))
+
+	assert.Equal(t, "", Measures{}.MarkdownTable(metrics, nil, ""))
+}
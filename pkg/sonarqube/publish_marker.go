@@ -0,0 +1,44 @@
+package sonarqube
+
+import "fmt"
+
+const (
+	TRANSITION_SET_IN_REVIEW  = "setinreview"
+	TRANSITION_FALSE_POSITIVE = "falsepositive"
+)
+
+// PublishMarker tells how the published issues are marked in Sonarqube
+type PublishMarker struct {
+	// Tag added to the published issues so they aren't published again, empty to not tag them
+	Tag string
+	// Transition applied to the published issues, empty to keep their status
+	Transition string
+	// Comment adds a comment with the link to the PR discussion into the published issues
+	Comment bool
+}
+
+// DefaultPublishMarker tags the issues as published and moves them to in review
+func DefaultPublishMarker() PublishMarker {
+	return PublishMarker{
+		Tag:        TAG_PUBLISHED,
+		Transition: TRANSITION_SET_IN_REVIEW,
+	}
+}
+
+// Unpublished filters out the issues already marked as published, without a tag all of them are unpublished
+func (m PublishMarker) Unpublished(issues *Issues) *Issues {
+	if m.Tag == "" {
+		return issues
+	}
+
+	return issues.FilterOutByTag(m.Tag)
+}
+
+// IssueComment returns the comment added into a published issue, linking to its review comment when known
+func (m PublishMarker) IssueComment(reviewURL string, commentURL string) string {
+	if commentURL != "" {
+		return fmt.Sprintf("Discussed in %s", commentURL)
+	}
+
+	return fmt.Sprintf("Discussed in the pull request review %s", reviewURL)
+}
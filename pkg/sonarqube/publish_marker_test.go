@@ -0,0 +1,26 @@
+package sonarqube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishMarkerUnpublished(t *testing.T) {
+	issues := &Issues{Issues: []Issue{
+		{Key: "a", Tags: []string{"published"}},
+		{Key: "b", Tags: []string{"sqpr"}},
+		{Key: "c"},
+	}}
+
+	assert.Len(t, DefaultPublishMarker().Unpublished(issues).Issues, 2)
+	assert.Equal(t, "c", PublishMarker{Tag: "sqpr"}.Unpublished(issues).Issues[1].Key)
+	assert.Len(t, PublishMarker{}.Unpublished(issues).Issues, 3)
+}
+
+func TestPublishMarkerIssueComment(t *testing.T) {
+	marker := PublishMarker{Comment: true}
+
+	assert.Equal(t, "Discussed in https://github.com/owner/repo/pull/3#discussion_r90", marker.IssueComment("https://github.com/owner/repo/pull/3#pullrequestreview-80", "https://github.com/owner/repo/pull/3#discussion_r90"))
+	assert.Equal(t, "Discussed in the pull request review https://github.com/owner/repo/pull/3#pullrequestreview-80", marker.IssueComment("https://github.com/owner/repo/pull/3#pullrequestreview-80", ""))
+}
@@ -4,6 +4,8 @@ type PullRequest struct {
 	Key    string `json:"key"`
 	Branch string `json:"branch"`
 	URL    string `json:"url"`
+	Base   string `json:"base"`
+	Target string `json:"target"`
 }
 
 type ProjectPullRequests struct {
@@ -0,0 +1,192 @@
+package sonarqube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// get executes a GET request to the given endpoint and parses the response into out
+func (s *Sonarqube) get(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	return s.request(ctx, http.MethodGet, endpoint, params, true, out)
+}
+
+// post executes a POST request to the given endpoint and parses the response into out
+func (s *Sonarqube) post(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	return s.request(ctx, http.MethodPost, endpoint, params, false, out)
+}
+
+// safePost executes a POST request that can be repeated without side effects, so it's retried like a GET
+func (s *Sonarqube) safePost(ctx context.Context, endpoint string, params url.Values, out interface{}) error {
+	return s.request(ctx, http.MethodPost, endpoint, params, true, out)
+}
+
+// request executes the request retrying the temporary failures when retryable
+func (s *Sonarqube) request(ctx context.Context, method string, endpoint string, params url.Values, retryable bool, out interface{}) error {
+	attempts := 1
+	if retryable && s.retry.attempts > 1 {
+		attempts = s.retry.attempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := s.do(ctx, method, endpoint, params, out)
+		if err == nil || attempt >= attempts {
+			return err
+		}
+
+		delay, ok := s.retry.delay(ctx, err, attempt)
+		if !ok {
+			return err
+		}
+
+		atomic.AddUint64(&s.retries, 1)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// do executes the request, checks the status code and parses the response into out
+func (s *Sonarqube) do(ctx context.Context, method string, endpoint string, params url.Values, out interface{}) error {
+	// Create a new request, POST params go in the body to avoid the URL length limits
+	var req *http.Request
+	var err error
+	if method == http.MethodPost {
+		req, err = http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", s.Root, endpoint), strings.NewReader(params.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s?%s", s.Root, endpoint, params.Encode()), nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Auth
+	if s.useBearerAuth(ctx) {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.ApiKey))
+	} else {
+		req.SetBasicAuth(s.ApiKey, "")
+	}
+
+	// Execute request
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Read body
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to read %s response", endpoint))
+	}
+
+	// Check status
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		apiErr := newAPIError(res.StatusCode, endpoint, body)
+		apiErr.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+
+		return apiErr
+	}
+
+	// Nothing to parse
+	if out == nil {
+		return nil
+	}
+
+	// Parse body
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("failed to unmarshal %s response", endpoint))
+	}
+
+	return nil
+}
+
+// newAPIError creates an API error with the messages found in the given response body
+func newAPIError(statusCode int, endpoint string, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Endpoint:   endpoint,
+		Messages:   make([]string, 0),
+	}
+
+	var errorResponse apiErrorResponse
+	if err := json.Unmarshal(body, &errorResponse); err == nil {
+		for _, item := range errorResponse.Errors {
+			apiErr.Messages = append(apiErr.Messages, item.Msg)
+		}
+	}
+
+	return apiErr
+}
+
+// withOrganization adds the SonarCloud organization into the given params
+func (s *Sonarqube) withOrganization(params url.Values) url.Values {
+	if s.IsCloud() {
+		params.Set("organization", s.organization)
+	}
+
+	return params
+}
+
+// useBearerAuth checks if the token should be sent as bearer, when the version is unknown basic auth is used
+func (s *Sonarqube) useBearerAuth(ctx context.Context) bool {
+	if s.IsCloud() {
+		return true
+	}
+
+	version, err := s.VersionContext(ctx)
+	if err != nil {
+		return false
+	}
+
+	return version.SupportsBearerAuth()
+}
+
+// probeVersion reads the server version, the endpoint is public so no auth is sent
+func (s *Sonarqube) probeVersion(ctx context.Context) (*Version, error) {
+	// Create a new request
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/server/version", s.Root), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Execute request
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// Read body
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read /api/server/version response")
+	}
+
+	// Check status
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, newAPIError(res.StatusCode, "/api/server/version", body)
+	}
+
+	version, err := ParseVersion(string(body))
+	if err != nil {
+		return nil, &invalidVersionError{cause: err}
+	}
+
+	return version, nil
+}
@@ -0,0 +1,99 @@
+package sonarqube
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxRetryAfter is the longest Retry-After honoured, longer waits are left to the caller
+const maxRetryAfter = time.Minute
+
+type retryConfig struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+// WithRetry retries the idempotent requests that failed temporarily, waiting an exponential backoff with jitter between
+// the given delays, attempts lower than 2 disable the retries
+func WithRetry(attempts int, baseDelay time.Duration, maxDelay time.Duration) Option {
+	return func(s *Sonarqube) {
+		s.retry = retryConfig{
+			attempts:  attempts,
+			baseDelay: baseDelay,
+			maxDelay:  maxDelay,
+		}
+	}
+}
+
+// Retries returns how many requests were retried since the instance was created
+func (s *Sonarqube) Retries() uint64 {
+	return atomic.LoadUint64(&s.retries)
+}
+
+// delay returns how long to wait before the next attempt, or false when the error isn't worth retrying
+func (c retryConfig) delay(ctx context.Context, err error, attempt int) (time.Duration, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if !apiErr.Temporary() || apiErr.RetryAfter > maxRetryAfter {
+			return 0, false
+		}
+
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+
+		return c.backoff(attempt), true
+	}
+
+	// Connection failures
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return c.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns a random delay up to the exponential backoff of the given attempt
+func (c retryConfig) backoff(attempt int) time.Duration {
+	delay := c.maxDelay
+	if shift := uint(attempt - 1); shift < 32 && c.baseDelay<<shift < c.maxDelay {
+		delay = c.baseDelay << shift
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	// Full jitter avoids retrying in lockstep with the other workers
+	return time.Duration(rand.Int63n(int64(delay)) + 1)
+}
+
+// parseRetryAfter reads the Retry-After header, either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
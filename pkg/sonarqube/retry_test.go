@@ -0,0 +1,154 @@
+package sonarqube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSonarqubeRetriesTemporaryErrors(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.Write([]byte(`{"pullRequests":[{"key":"3","branch":"my-branch"}]}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+	prs, err := sonar.ProjectPullRequests("myproject")
+	assert.NoError(t, err)
+	assert.Len(t, prs.PullRequests, 1)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, uint64(2), sonar.Retries())
+}
+
+func TestSonarqubeRetriesGiveUp(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithRetry(2, time.Millisecond, 5*time.Millisecond))
+
+	_, err := sonar.ProjectPullRequests("myproject")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, uint64(1), sonar.Retries())
+}
+
+func TestSonarqubeDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+	_, err := sonar.ProjectPullRequests("myproject")
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, uint64(0), sonar.Retries())
+}
+
+func TestSonarqubeDoesNotRetryUnsafePost(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+	err := sonar.post(context.Background(), "/api/issues/do_transition", nil, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestSonarqubeHonoursRetryAfter(t *testing.T) {
+	var calls int32
+	var first time.Time
+	var second time.Time
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		second = time.Now()
+		w.Write([]byte(`{"pullRequests":[]}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithRetry(2, time.Millisecond, 5*time.Millisecond))
+
+	_, err := sonar.ProjectPullRequests("myproject")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, second.Sub(first), time.Second)
+}
+
+func TestSonarqubeGivesUpOnLongRetryAfter(t *testing.T) {
+	var calls int32
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithRetry(3, time.Millisecond, 5*time.Millisecond))
+
+	_, err := sonar.ProjectPullRequests("myproject")
+
+	var apiErr *APIError
+	assert.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, time.Hour, apiErr.RetryAfter)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 11, 10, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120", now))
+	assert.Equal(t, 30*time.Second, parseRetryAfter("Wed, 10 Nov 2021 12:00:30 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("Wed, 10 Nov 2021 11:00:00 GMT", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("", now))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon", now))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	config := retryConfig{attempts: 10, baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	for attempt := 1; attempt < 10; attempt++ {
+		delay := config.backoff(attempt)
+		assert.Greater(t, delay, time.Duration(0))
+		assert.LessOrEqual(t, delay, time.Second)
+	}
+	assert.LessOrEqual(t, config.backoff(1), 100*time.Millisecond)
+}
@@ -0,0 +1,117 @@
+package sonarqube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sonarCloudStandIn serves the recorded SonarCloud responses in testdata/sonarcloud
+func sonarCloudStandIn(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer mytoken", r.Header.Get("Authorization"))
+
+		fixture := strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/api/"), "/", "_")
+		body, err := os.ReadFile(filepath.Join("testdata", "sonarcloud", fixture+".json"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"msg":"Unknown url : ` + r.URL.Path + `"}]}`))
+
+			return
+		}
+
+		w.Write(body)
+	}))
+}
+
+func TestSonarCloudDefaultRoot(t *testing.T) {
+	sonar := New("", "mytoken", WithSonarCloud("myorg"))
+
+	assert.Equal(t, SONARCLOUD_ROOT, sonar.Root)
+	assert.True(t, sonar.IsCloud())
+	assert.Equal(t, "myorg", sonar.Organization())
+	assert.False(t, New("https://my-root", "apiKey").IsCloud())
+}
+
+func TestSonarCloudFindPRForBranch(t *testing.T) {
+	svr := sonarCloudStandIn(t)
+	defer svr.Close()
+
+	sonar := New(svr.URL, "mytoken", WithSonarCloud("myorg"))
+
+	pr, err := sonar.FindPRForBranch("myorg_myproject", "feat/parser")
+	assert.NoError(t, err)
+	assert.Equal(t, "12", pr.Key)
+	assert.Equal(t, "main", pr.Target)
+	assert.Equal(t, "https://github.com/myorg/myproject/pull/12", pr.URL)
+}
+
+func TestSonarCloudListIssuesForPR(t *testing.T) {
+	svr := sonarCloudStandIn(t)
+	defer svr.Close()
+
+	var query map[string][]string
+	sonar := New(svr.URL, "mytoken", WithSonarCloud("myorg"), WithTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		query = req.URL.Query()
+
+		return http.DefaultTransport.RoundTrip(req)
+	})))
+
+	issues, err := sonar.ListIssuesForPR("myorg_myproject", "12")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"myorg"}, query["organization"])
+
+	assert.Equal(t, 2, len(issues.Issues))
+	assert.Equal(t, "parser/parser.go", issues.Issues[0].FilePath())
+	assert.Equal(t, 38, issues.Issues[0].SecondaryLocations()[0].Line())
+	assert.Equal(t, "CRITICAL", issues.Issues[1].Severity)
+}
+
+func TestSonarCloudMarkIssues(t *testing.T) {
+	svr := sonarCloudStandIn(t)
+	defer svr.Close()
+
+	var query map[string][]string
+	sonar := New(svr.URL, "mytoken", WithSonarCloud("myorg"), WithTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(req.Body)
+		assert.NoError(t, err)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		query, err = url.ParseQuery(string(body))
+		assert.NoError(t, err)
+
+		return http.DefaultTransport.RoundTrip(req)
+	})))
+
+	bulkResponse, err := sonar.MarkIssuesContext(context.Background(), []Issue{{Key: "AX2qL0cT4bD1Yq9gXk1a"}, {Key: "AX2qL0cT4bD1Yq9gXk1b"}}, DefaultPublishMarker())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bulkResponse.Success)
+	assert.Equal(t, []string{TAG_PUBLISHED}, query["add_tags"])
+
+	// SonarCloud doesn't support the in review transition
+	_, ok := query["do_transition"]
+	assert.False(t, ok)
+}
+
+func TestSonarCloudWebhook(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "sonarcloud", "webhook.json"))
+	assert.NoError(t, err)
+
+	var webhook WebhookData
+	assert.NoError(t, json.Unmarshal(body, &webhook))
+
+	assert.Equal(t, "myorg", webhook.Organization)
+	assert.Equal(t, "myorg_myproject", webhook.Project.Key)
+	assert.Equal(t, BRANCH_TYPE_PULL_REQUEST, webhook.Branch.Type)
+	assert.Equal(t, "12", webhook.BranchName())
+	assert.Equal(t, "4e7a7bd4a5a6e1cbd7b42ae4f3a4e2d6f0c1b8a9", webhook.Revision)
+}
@@ -1,11 +1,12 @@
 package sonarqube
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,63 +15,205 @@ import (
 const (
 	TAG_PUBLISHED            = "published"
 	BRANCH_TYPE_PULL_REQUEST = "PULL_REQUEST"
+	SONARCLOUD_ROOT          = "https://sonarcloud.io"
 )
 
+// BULK_CHANGE_MAX_ISSUES is the maximum of issues Sonarqube accepts in a single bulk change
+const BULK_CHANGE_MAX_ISSUES = 500
+
+// issueLookupBatch is the amount of issue keys searched at once, small enough to fit in the URL
+const issueLookupBatch = 100
+
 type BulkActionResponse struct {
 	Total    int `json:"total"`
 	Success  int `json:"success"`
 	Ignored  int `json:"ignored"`
 	Failures int `json:"failures"`
+	// FailedIssues contains the keys of the issues that weren't changed
+	FailedIssues []string `json:"-"`
+}
+
+// add aggregates the given batch response
+func (b *BulkActionResponse) add(other BulkActionResponse) {
+	b.Total += other.Total
+	b.Success += other.Success
+	b.Ignored += other.Ignored
+	b.Failures += other.Failures
+	b.FailedIssues = append(b.FailedIssues, other.FailedIssues...)
 }
 
 type Sonarqube struct {
 	Root   string
 	ApiKey string
 
-	httpClient *http.Client
+	httpClient   *http.Client
+	organization string
+	retry        retryConfig
+	retries      uint64
+
+	versionMutex sync.Mutex
+	version      *Version
+	versionErr   error
+}
+
+type Option func(*Sonarqube)
+
+// WithTimeout limits the time of each request, including reading the response body
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Sonarqube) {
+		s.httpClient.Timeout = timeout
+	}
+}
+
+// WithHTTPClient uses a copy of the given client for the requests, options applied after it change
+// the copy so the given client can be shared
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Sonarqube) {
+		copied := *client
+		s.httpClient = &copied
+	}
+}
+
+// WithTransport uses the given round tripper to execute the requests
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *Sonarqube) {
+		s.httpClient.Transport = rt
+	}
+}
+
+// WithSonarCloud talks to SonarCloud in the given organization, the root defaults to sonarcloud.io
+func WithSonarCloud(organization string) Option {
+	return func(s *Sonarqube) {
+		s.organization = organization
+		if s.Root == "" {
+			s.Root = SONARCLOUD_ROOT
+		}
+	}
+}
+
+// WithServerVersion skips probing the server version, useful when /api/server/version isn't reachable
+func WithServerVersion(version *Version) Option {
+	return func(s *Sonarqube) {
+		s.version = version
+	}
 }
 
 // New creates a new Sonarqube instance
-func New(root string, apiKey string) *Sonarqube {
+func New(root string, apiKey string, opts ...Option) *Sonarqube {
 	// Create a new http client with timeout
 	httpClient := &http.Client{
 		Timeout: time.Second * 10,
 	}
 
-	return &Sonarqube{
+	sonar := &Sonarqube{
 		Root:   root,
 		ApiKey: apiKey,
 
 		httpClient: httpClient,
+		retry: retryConfig{
+			attempts:  3,
+			baseDelay: 500 * time.Millisecond,
+			maxDelay:  10 * time.Second,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(sonar)
 	}
+
+	return sonar
 }
 
-// ProjectPullRequests reads all the PRs for the given project ID
-func (s *Sonarqube) ProjectPullRequests(projectId string) (*ProjectPullRequests, error) {
-	// Create a new request
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/project_pull_requests/list?project=%s", s.Root, projectId), nil)
+// IsCloud checks if the instance talks to SonarCloud
+func (s *Sonarqube) IsCloud() bool {
+	return s.organization != ""
+}
+
+// Organization returns the SonarCloud organization
+func (s *Sonarqube) Organization() string {
+	return s.organization
+}
+
+// Version returns the server version, probed only once
+func (s *Sonarqube) Version() (*Version, error) {
+	return s.VersionContext(context.Background())
+}
+
+// VersionContext returns the server version, probed only once
+func (s *Sonarqube) VersionContext(ctx context.Context) (*Version, error) {
+	s.versionMutex.Lock()
+	version, versionErr := s.version, s.versionErr
+	s.versionMutex.Unlock()
+
+	if version != nil || versionErr != nil {
+		return version, versionErr
+	}
+
+	// The lock isn't held while probing so a slow server doesn't block the other callers,
+	// concurrent calls may probe at the same time until one of them succeeds
+	version, err := s.probeVersion(ctx)
 	if err != nil {
+		// Connection failures and transient server errors are probed again in the next call
+		if !isPermanentVersionErr(err) {
+			return nil, err
+		}
+
+		s.versionMutex.Lock()
+		s.versionErr = err
+		s.versionMutex.Unlock()
+
 		return nil, err
 	}
 
-	// Auth
-	req.SetBasicAuth(s.ApiKey, "")
+	s.versionMutex.Lock()
+	s.version = version
+	s.versionMutex.Unlock()
 
-	// Execute request
-	res, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, err
+	return version, nil
+}
+
+// isPermanentVersionErr checks if probing the version again would fail the same way,
+// like when the endpoint is refused or answers something that isn't a version
+func isPermanentVersionErr(err error) bool {
+	if errors.Is(err, errInvalidVersion) {
+		return true
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
 	}
 
-	// Read response body
-	body, err := io.ReadAll(res.Body)
+	return apiErr.StatusCode >= http.StatusBadRequest && apiErr.StatusCode < http.StatusInternalServerError && !apiErr.IsRateLimit()
+}
+
+// ValidateToken checks if the API key is accepted by Sonarqube
+func (s *Sonarqube) ValidateToken() (bool, error) {
+	return s.ValidateTokenContext(context.Background())
+}
+
+// ValidateTokenContext checks if the API key is accepted by Sonarqube
+func (s *Sonarqube) ValidateTokenContext(ctx context.Context) (bool, error) {
+	var data struct {
+		Valid bool `json:"valid"`
+	}
+	err := s.get(ctx, "/api/authentication/validate", url.Values{}, &data)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	// Parse response body
+	return data.Valid, nil
+}
+
+// ProjectPullRequests reads all the PRs for the given project ID
+func (s *Sonarqube) ProjectPullRequests(projectId string) (*ProjectPullRequests, error) {
+	return s.ProjectPullRequestsContext(context.Background(), projectId)
+}
+
+// ProjectPullRequestsContext reads all the PRs for the given project ID
+func (s *Sonarqube) ProjectPullRequestsContext(ctx context.Context, projectId string) (*ProjectPullRequests, error) {
 	var data ProjectPullRequests
-	err = json.Unmarshal(body, &data)
+	err := s.get(ctx, "/api/project_pull_requests/list", s.withOrganization(url.Values{"project": {projectId}}), &data)
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +223,13 @@ func (s *Sonarqube) ProjectPullRequests(projectId string) (*ProjectPullRequests,
 
 // FindPRForKey searches the pull request for the given project and key
 func (s *Sonarqube) FindPRForKey(project string, key string) (*PullRequest, error) {
+	return s.FindPRForKeyContext(context.Background(), project, key)
+}
+
+// FindPRForKeyContext searches the pull request for the given project and key
+func (s *Sonarqube) FindPRForKeyContext(ctx context.Context, project string, key string) (*PullRequest, error) {
 	// Fetch project pull requests
-	pullRequests, err := s.ProjectPullRequests(project)
+	pullRequests, err := s.ProjectPullRequestsContext(ctx, project)
 	if err != nil {
 		return nil, err
 	}
@@ -93,13 +241,18 @@ func (s *Sonarqube) FindPRForKey(project string, key string) (*PullRequest, erro
 		}
 	}
 
-	return nil, errors.New("not found")
+	return nil, ErrNotFound
 }
 
 // FindPRForBranch searches the pull request for the given project and branch
 func (s *Sonarqube) FindPRForBranch(project string, branch string) (*PullRequest, error) {
+	return s.FindPRForBranchContext(context.Background(), project, branch)
+}
+
+// FindPRForBranchContext searches the pull request for the given project and branch
+func (s *Sonarqube) FindPRForBranchContext(ctx context.Context, project string, branch string) (*PullRequest, error) {
 	// Fetch project pull requests
-	pullRequests, err := s.ProjectPullRequests(project)
+	pullRequests, err := s.ProjectPullRequestsContext(ctx, project)
 	if err != nil {
 		return nil, err
 	}
@@ -111,35 +264,135 @@ func (s *Sonarqube) FindPRForBranch(project string, branch string) (*PullRequest
 		}
 	}
 
-	return nil, errors.New("not found")
+	return nil, ErrNotFound
 }
 
 // ListIssuesForPR list the issues for the given project and PR
 func (s *Sonarqube) ListIssuesForPR(project string, prNumber string) (*Issues, error) {
-	// Create a new request
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/issues/search?pullRequest=%s&componentKeys=%s", s.Root, prNumber, project), nil)
+	return s.ListIssuesForPRContext(context.Background(), project, prNumber)
+}
+
+// ListIssuesForPRContext list the issues for the given project and PR
+func (s *Sonarqube) ListIssuesForPRContext(ctx context.Context, project string, prNumber string) (*Issues, error) {
+	// Newer versions renamed the components param
+	componentsParam := "componentKeys"
+	if !s.IsCloud() {
+		if version, err := s.VersionContext(ctx); err == nil && version.SupportsImpacts() {
+			componentsParam = "components"
+		}
+	}
+
+	var data Issues
+	err := s.get(ctx, "/api/issues/search", s.withOrganization(url.Values{"pullRequest": {prNumber}, componentsParam: {project}}), &data)
 	if err != nil {
 		return nil, err
 	}
 
-	// Auth
-	req.SetBasicAuth(s.ApiKey, "")
+	return &data, nil
+}
+
+// ListHotspotsForPR list the security hotspots for the given project and PR
+func (s *Sonarqube) ListHotspotsForPR(project string, prNumber string) (*Hotspots, error) {
+	return s.ListHotspotsForPRContext(context.Background(), project, prNumber)
+}
 
-	// Execute request
-	res, err := s.httpClient.Do(req)
+// ListHotspotsForPRContext list the security hotspots for the given project and PR
+func (s *Sonarqube) ListHotspotsForPRContext(ctx context.Context, project string, prNumber string) (*Hotspots, error) {
+	var data Hotspots
+	err := s.get(ctx, "/api/hotspots/search", url.Values{"pullRequest": {prNumber}, "projectKey": {project}}, &data)
 	if err != nil {
 		return nil, err
 	}
 
-	// Read body
-	body, err := io.ReadAll(res.Body)
+	return &data, nil
+}
+
+// SourceLines reads the lines of the given file component, including its coverage, for the given PR
+func (s *Sonarqube) SourceLines(component string, prNumber string) (*SourceLines, error) {
+	return s.SourceLinesContext(context.Background(), component, prNumber)
+}
+
+// SourceLinesContext reads the lines of the given file component, including its coverage, for the given PR
+func (s *Sonarqube) SourceLinesContext(ctx context.Context, component string, prNumber string) (*SourceLines, error) {
+	var data SourceLines
+	err := s.get(ctx, "/api/sources/lines", url.Values{"key": {component}, "pullRequest": {prNumber}}, &data)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse body
-	var data Issues
-	err = json.Unmarshal(body, &data)
+	return &data, nil
+}
+
+// Duplications reads the duplicated blocks of the given file component for the given PR
+func (s *Sonarqube) Duplications(componentKey string, pullRequest string) (*Duplications, error) {
+	return s.DuplicationsContext(context.Background(), componentKey, pullRequest)
+}
+
+// DuplicationsContext reads the duplicated blocks of the given file component for the given PR
+func (s *Sonarqube) DuplicationsContext(ctx context.Context, componentKey string, pullRequest string) (*Duplications, error) {
+	var data Duplications
+	err := s.get(ctx, "/api/duplications/show", url.Values{"key": {componentKey}, "pullRequest": {pullRequest}}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// PullRequestMeasures reads the given metrics of the project for the given PR
+func (s *Sonarqube) PullRequestMeasures(project string, pullRequest string, metricKeys []string) (*Measures, error) {
+	return s.PullRequestMeasuresContext(context.Background(), project, pullRequest, metricKeys)
+}
+
+// PullRequestMeasuresContext reads the given metrics of the project for the given PR
+func (s *Sonarqube) PullRequestMeasuresContext(ctx context.Context, project string, pullRequest string, metricKeys []string) (*Measures, error) {
+	return s.componentMeasures(ctx, url.Values{"component": {project}, "pullRequest": {pullRequest}, "metricKeys": {strings.Join(metricKeys, ",")}})
+}
+
+// BranchMeasures reads the given metrics of the project for the given branch
+func (s *Sonarqube) BranchMeasures(project string, branch string, metricKeys []string) (*Measures, error) {
+	return s.BranchMeasuresContext(context.Background(), project, branch, metricKeys)
+}
+
+// BranchMeasuresContext reads the given metrics of the project for the given branch
+func (s *Sonarqube) BranchMeasuresContext(ctx context.Context, project string, branch string, metricKeys []string) (*Measures, error) {
+	return s.componentMeasures(ctx, url.Values{"component": {project}, "branch": {branch}, "metricKeys": {strings.Join(metricKeys, ",")}})
+}
+
+// PullRequestSummary creates a markdown table with the given metrics of the PR compared with its target branch
+func (s *Sonarqube) PullRequestSummary(project string, pr *PullRequest, metricKeys []string) (string, error) {
+	return s.PullRequestSummaryContext(context.Background(), project, pr, metricKeys)
+}
+
+// PullRequestSummaryContext creates a markdown table with the given metrics of the PR compared with its target branch
+func (s *Sonarqube) PullRequestSummaryContext(ctx context.Context, project string, pr *PullRequest, metricKeys []string) (string, error) {
+	measures, err := s.PullRequestMeasuresContext(ctx, project, pr.Key, metricKeys)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read PR measures")
+	}
+
+	// Without target branch there is nothing to compare with
+	if pr.Target == "" {
+		return measures.MarkdownTable(metricKeys, nil, ""), nil
+	}
+
+	branchMetricKeys := make([]string, 0, len(metricKeys))
+	for _, metric := range metricKeys {
+		branchMetricKeys = append(branchMetricKeys, BranchMetric(metric))
+	}
+
+	targetMeasures, err := s.BranchMeasuresContext(ctx, project, pr.Target, branchMetricKeys)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read target branch measures")
+	}
+
+	return measures.MarkdownTable(metricKeys, targetMeasures, pr.Target), nil
+}
+
+// componentMeasures reads the measures of a component with the given query params
+func (s *Sonarqube) componentMeasures(ctx context.Context, params url.Values) (*Measures, error) {
+	var data Measures
+	err := s.get(ctx, "/api/measures/component", params, &data)
 	if err != nil {
 		return nil, err
 	}
@@ -149,40 +402,215 @@ func (s *Sonarqube) ListIssuesForPR(project string, prNumber string) (*Issues, e
 
 // TagIssues adds a given tag into the given issues
 func (s *Sonarqube) TagIssues(issues []Issue, tags string) (*BulkActionResponse, error) {
+	return s.TagIssuesContext(context.Background(), issues, tags)
+}
+
+// TagIssuesContext adds a given tag into the given issues
+func (s *Sonarqube) TagIssuesContext(ctx context.Context, issues []Issue, tags string) (*BulkActionResponse, error) {
+	return s.bulkChange(ctx, issues, tags, "")
+}
+
+// MarkIssuesContext marks the given issues as published by adding the marker tag and applying its transition
+func (s *Sonarqube) MarkIssuesContext(ctx context.Context, issues []Issue, marker PublishMarker) (*BulkActionResponse, error) {
+	transition := marker.Transition
+
+	// SonarCloud doesn't have the in review status
+	if s.IsCloud() && transition == TRANSITION_SET_IN_REVIEW {
+		transition = ""
+	}
+
+	// Nothing to change
+	if marker.Tag == "" && transition == "" {
+		return &BulkActionResponse{Total: len(issues), Ignored: len(issues), FailedIssues: make([]string, 0)}, nil
+	}
+
+	return s.bulkChange(ctx, issues, marker.Tag, transition)
+}
+
+// bulkChange adds the tags and applies the transition to the given issues, in batches of BULK_CHANGE_MAX_ISSUES.
+// When a batch fails the others are still changed, the returned response aggregates all of them
+// and lists the issues that weren't changed along with the error
+func (s *Sonarqube) bulkChange(ctx context.Context, issues []Issue, tags string, transition string) (*BulkActionResponse, error) {
 	issueKeys := make([]string, 0)
 	for _, issue := range issues {
 		issueKeys = append(issueKeys, issue.Key)
 	}
 
-	// Request URL
-	params := fmt.Sprintf("issues=%s&add_tags=%s&do_transition=setinreview", strings.Join(issueKeys, ","), tags)
+	bulkRes := &BulkActionResponse{FailedIssues: make([]string, 0)}
+	var batchErr error
+	for start := 0; start < len(issueKeys); start += BULK_CHANGE_MAX_ISSUES {
+		end := start + BULK_CHANGE_MAX_ISSUES
+		if end > len(issueKeys) {
+			end = len(issueKeys)
+		}
+		batch := issueKeys[start:end]
+
+		batchRes, err := s.bulkChangeBatch(ctx, batch, tags, transition)
+		if err != nil {
+			bulkRes.add(BulkActionResponse{Total: len(batch), Failures: len(batch), FailedIssues: batch})
+			if batchErr == nil {
+				batchErr = err
+			}
+
+			continue
+		}
+
+		bulkRes.add(*batchRes)
+	}
 
-	// Create a new request
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/issues/bulk_change?%s", s.Root, params), nil)
+	if batchErr != nil {
+		return bulkRes, errors.Wrap(batchErr, fmt.Sprintf("failed to change %d of %d issues", len(bulkRes.FailedIssues), len(issueKeys)))
+	}
+
+	return bulkRes, nil
+}
+
+// bulkChangeBatch adds the tags and applies the transition to a single batch of issues
+func (s *Sonarqube) bulkChangeBatch(ctx context.Context, issueKeys []string, tags string, transition string) (*BulkActionResponse, error) {
+	// Request params
+	params := url.Values{"issues": {strings.Join(issueKeys, ",")}}
+	if tags != "" {
+		params.Set("add_tags", tags)
+	}
+	if transition != "" {
+		params.Set("do_transition", transition)
+	}
+
+	var bulkRes BulkActionResponse
+	err := s.safePost(ctx, "/api/issues/bulk_change", params, &bulkRes)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "failed to change issues")
+	}
+
+	bulkRes.FailedIssues = make([]string, 0)
+	if bulkRes.Failures == 0 {
+		return &bulkRes, nil
 	}
 
-	// Auth
-	req.SetBasicAuth(s.ApiKey, "")
+	// Sonarqube only counts the failures, look for the issues that are still missing the tags
+	bulkRes.FailedIssues = issueKeys
+	if tags != "" {
+		failed, err := s.issuesWithoutTags(ctx, issueKeys, strings.Split(tags, ","))
+		if err == nil {
+			bulkRes.FailedIssues = failed
+		}
+	}
+
+	return &bulkRes, nil
+}
 
-	// Execute request
-	res, err := s.httpClient.Do(req)
+// TransitionIssueContext applies the given transition to the issue, like falsepositive or wontfix
+func (s *Sonarqube) TransitionIssueContext(ctx context.Context, issueKey string, transition string) error {
+	err := s.post(ctx, "/api/issues/do_transition", url.Values{"issue": {issueKey}, "transition": {transition}}, nil)
 	if err != nil {
-		return nil, err
+		return errors.Wrap(err, fmt.Sprintf("failed to apply transition %s to issue %s", transition, issueKey))
 	}
 
-	// Read body
-	body, err := io.ReadAll(res.Body)
+	return nil
+}
+
+// IssueContext returns the given issue with the transitions the token can apply to it,
+// the ones that don't fit its current status, like resolving it twice, aren't listed
+func (s *Sonarqube) IssueContext(ctx context.Context, issueKey string) (*Issue, error) {
+	var data Issues
+	params := url.Values{"issues": {issueKey}, "additionalFields": {"transitions"}}
+	err := s.get(ctx, "/api/issues/search", s.withOrganization(params), &data)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read tag issues response")
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to read issue %s", issueKey))
 	}
 
-	var bulkRes BulkActionResponse
-	err = json.Unmarshal(body, &bulkRes)
+	for _, issue := range data.Issues {
+		if issue.Key == issueKey {
+			return &issue, nil
+		}
+	}
+
+	return nil, errors.Wrap(ErrNotFound, fmt.Sprintf("failed to find issue %s", issueKey))
+}
+
+// CommentIssuesContext adds a comment into each one of the given issues with the text returned for it
+func (s *Sonarqube) CommentIssuesContext(ctx context.Context, issues []Issue, text func(issue Issue) string) (*BulkActionResponse, error) {
+	commentRes := &BulkActionResponse{FailedIssues: make([]string, 0)}
+	var commentErr error
+	for _, issue := range issues {
+		commentRes.Total++
+
+		err := s.CommentIssueContext(ctx, issue.Key, text(issue))
+		if err != nil {
+			commentRes.Failures++
+			commentRes.FailedIssues = append(commentRes.FailedIssues, issue.Key)
+			if commentErr == nil {
+				commentErr = err
+			}
+
+			continue
+		}
+
+		commentRes.Success++
+	}
+
+	if commentErr != nil {
+		return commentRes, errors.Wrap(commentErr, fmt.Sprintf("failed to comment %d of %d issues", commentRes.Failures, commentRes.Total))
+	}
+
+	return commentRes, nil
+}
+
+// CommentIssueContext adds the given comment into the issue, it's not retried to avoid duplicated comments
+func (s *Sonarqube) CommentIssueContext(ctx context.Context, issueKey string, text string) error {
+	err := s.post(ctx, "/api/issues/add_comment", url.Values{"issue": {issueKey}, "text": {text}}, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to unmarshal tag issues response")
+		return errors.Wrap(err, fmt.Sprintf("failed to comment issue %s", issueKey))
 	}
 
-	return &bulkRes, nil
+	return nil
+}
+
+// issuesWithoutTags returns the keys of the given issues that don't contain all the given tags
+func (s *Sonarqube) issuesWithoutTags(ctx context.Context, issueKeys []string, tags []string) ([]string, error) {
+	found := make(map[string][]string)
+	for start := 0; start < len(issueKeys); start += issueLookupBatch {
+		end := start + issueLookupBatch
+		if end > len(issueKeys) {
+			end = len(issueKeys)
+		}
+
+		var data Issues
+		params := url.Values{"issues": {strings.Join(issueKeys[start:end], ",")}, "ps": {fmt.Sprint(issueLookupBatch)}}
+		err := s.get(ctx, "/api/issues/search", s.withOrganization(params), &data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to search the tagged issues")
+		}
+
+		for _, issue := range data.Issues {
+			found[issue.Key] = issue.Tags
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, key := range issueKeys {
+		if !containsAll(found[key], tags) {
+			missing = append(missing, key)
+		}
+	}
+
+	return missing, nil
+}
+
+// containsAll checks if all the wanted items are present in items
+func containsAll(items []string, wanted []string) bool {
+	for _, item := range wanted {
+		isFound := false
+		for _, existing := range items {
+			if existing == item {
+				isFound = true
+			}
+		}
+
+		if !isFound {
+			return false
+		}
+	}
+
+	return true
 }
@@ -1,9 +1,16 @@
 package sonarqube
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -132,3 +139,522 @@ func TestSonarqubeTagIssues(t *testing.T) {
 	assert.Equal(t, 0, bulkResponse.Failures)
 	assert.Equal(t, 0, bulkResponse.Ignored)
 }
+
+func TestSonarqubeTagIssuesBatches(t *testing.T) {
+	batches := make([][]string, 0)
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/issues/bulk_change", r.URL.Path)
+		assert.Empty(t, r.URL.RawQuery)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, TAG_PUBLISHED, r.PostForm.Get("add_tags"))
+
+		keys := strings.Split(r.PostForm.Get("issues"), ",")
+		batches = append(batches, keys)
+		fmt.Fprintf(w, `{"total":%d,"success":%d,"ignored":0,"failures":0}`, len(keys), len(keys))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	issues := make([]Issue, 0)
+	for i := 0; i < 1001; i++ {
+		issues = append(issues, Issue{Key: fmt.Sprintf("issue-%d", i)})
+	}
+
+	bulkResponse, err := sonar.TagIssues(issues, TAG_PUBLISHED)
+	assert.NoError(t, err)
+
+	assert.Len(t, batches, 3)
+	assert.Len(t, batches[0], BULK_CHANGE_MAX_ISSUES)
+	assert.Len(t, batches[1], BULK_CHANGE_MAX_ISSUES)
+	assert.Equal(t, []string{"issue-1000"}, batches[2])
+	assert.Equal(t, 1001, bulkResponse.Total)
+	assert.Equal(t, 1001, bulkResponse.Success)
+	assert.Empty(t, bulkResponse.FailedIssues)
+}
+
+func TestSonarqubeTagIssuesReportsFailures(t *testing.T) {
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/issues/bulk_change":
+			w.Write([]byte(`{"total":3,"success":2,"ignored":0,"failures":1}`))
+		case "/api/issues/search":
+			assert.Equal(t, "a,b,c", r.URL.Query().Get("issues"))
+			w.Write([]byte(`{"issues":[{"key":"a","tags":["published"]},{"key":"b","tags":[]},{"key":"c","tags":["cwe","published"]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	bulkResponse, err := sonar.TagIssues([]Issue{{Key: "a"}, {Key: "b"}, {Key: "c"}}, TAG_PUBLISHED)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bulkResponse.Failures)
+	assert.Equal(t, []string{"b"}, bulkResponse.FailedIssues)
+}
+
+func TestSonarqubeTagIssuesFailedBatch(t *testing.T) {
+	calls := 0
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(`{"total":1,"success":1,"ignored":0,"failures":0}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	issues := make([]Issue, 0)
+	for i := 0; i <= BULK_CHANGE_MAX_ISSUES; i++ {
+		issues = append(issues, Issue{Key: fmt.Sprintf("issue-%d", i)})
+	}
+
+	bulkResponse, err := sonar.TagIssues(issues, TAG_PUBLISHED)
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, bulkResponse.Success)
+	assert.Equal(t, BULK_CHANGE_MAX_ISSUES, bulkResponse.Failures)
+	assert.Len(t, bulkResponse.FailedIssues, BULK_CHANGE_MAX_ISSUES)
+	assert.Equal(t, "issue-0", bulkResponse.FailedIssues[0])
+}
+
+func TestSonarqubeMarkIssues(t *testing.T) {
+	var form url.Values
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/issues/bulk_change", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		form = r.PostForm
+
+		w.Write([]byte(`{"total":1,"success":1,"ignored":0,"failures":0}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+	issues := []Issue{{Key: "AX2GHjk1-Wk2ioy15Nrv"}}
+
+	// Default marker
+	_, err := sonar.MarkIssuesContext(context.Background(), issues, DefaultPublishMarker())
+	assert.NoError(t, err)
+	assert.Equal(t, "published", form.Get("add_tags"))
+	assert.Equal(t, "setinreview", form.Get("do_transition"))
+
+	// Only tag
+	_, err = sonar.MarkIssuesContext(context.Background(), issues, PublishMarker{Tag: "sqpr"})
+	assert.NoError(t, err)
+	assert.Equal(t, "sqpr", form.Get("add_tags"))
+	_, ok := form["do_transition"]
+	assert.False(t, ok)
+
+	// Only transition
+	_, err = sonar.MarkIssuesContext(context.Background(), issues, PublishMarker{Transition: "confirm"})
+	assert.NoError(t, err)
+	assert.Equal(t, "confirm", form.Get("do_transition"))
+	_, ok = form["add_tags"]
+	assert.False(t, ok)
+
+	// Nothing to change
+	form = nil
+	bulkResponse, err := sonar.MarkIssuesContext(context.Background(), issues, PublishMarker{Comment: true})
+	assert.NoError(t, err)
+	assert.Nil(t, form)
+	assert.Equal(t, 1, bulkResponse.Ignored)
+}
+
+func TestSonarqubeCommentIssues(t *testing.T) {
+	commented := make([]string, 0)
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/issues/add_comment", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "Discussed in https://github.com/owner/repo/pull/3#discussion_"+r.PostForm.Get("issue"), r.PostForm.Get("text"))
+
+		if r.PostForm.Get("issue") == "b" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		commented = append(commented, r.PostForm.Get("issue"))
+		w.Write([]byte(`{}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	commentRes, err := sonar.CommentIssuesContext(context.Background(), []Issue{{Key: "a"}, {Key: "b"}, {Key: "c"}}, func(issue Issue) string {
+		return "Discussed in https://github.com/owner/repo/pull/3#discussion_" + issue.Key
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []string{"a", "c"}, commented)
+	assert.Equal(t, 2, commentRes.Success)
+	assert.Equal(t, []string{"b"}, commentRes.FailedIssues)
+}
+
+func TestSonarqubeTransitionIssue(t *testing.T) {
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/issues/do_transition", r.URL.Path)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "AX2GHjk1-Wk2ioy15Nrv", r.PostForm.Get("issue"))
+		assert.Equal(t, "falsepositive", r.PostForm.Get("transition"))
+
+		w.Write([]byte(`{}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	err := sonar.TransitionIssueContext(context.Background(), "AX2GHjk1-Wk2ioy15Nrv", "falsepositive")
+	assert.NoError(t, err)
+}
+
+func TestSonarqubeIssue(t *testing.T) {
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/issues/search", r.URL.Path)
+		assert.Equal(t, "transitions", r.URL.Query().Get("additionalFields"))
+		if r.URL.Query().Get("issues") != "AX2GHjk1-Wk2ioy15Nrv" {
+			w.Write([]byte(`{"total":0,"p":1,"ps":100,"issues":[]}`))
+
+			return
+		}
+
+		w.Write([]byte(`{"total":1,"p":1,"ps":100,"issues":[{"key":"AX2GHjk1-Wk2ioy15Nrv","project":"myproject","status":"OPEN","transitions":["confirm","resolve","falsepositive","wontfix"]}]}`))
+	}))
+	defer svr.Close()
+
+	sonar := New(svr.URL, "myapikey")
+
+	issue, err := sonar.IssueContext(context.Background(), "AX2GHjk1-Wk2ioy15Nrv")
+	assert.NoError(t, err)
+	assert.Equal(t, "myproject", issue.Project)
+	assert.Equal(t, []string{"confirm", "resolve", "falsepositive", "wontfix"}, issue.Transitions)
+
+	_, err = sonar.IssueContext(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSonarqubeListHotspotsForPR(t *testing.T) {
+	// Mock response
+	expected := `{"paging":{"pageIndex":1,"pageSize":100,"total":2},"hotspots":[{"key":"AX2GHjk1-Wk2ioy15Nrx","component":"myorg_myproject:pkg/db.go","project":"myorg_myproject","securityCategory":"sql-injection","vulnerabilityProbability":"HIGH","status":"TO_REVIEW","line":42,"message":"Make sure using a dynamically formatted SQL query is safe here.","author":"herlon214@gmail.com","creationDate":"2021-12-04T15:43:23+0000","updateDate":"2021-12-04T15:43:23+0000","textRange":{"startLine":42,"endLine":42,"startOffset":8,"endOffset":30},"flows":[],"ruleKey":"go:S2077"},{"key":"AX2GHjk1-Wk2ioy15Nry","component":"myorg_myproject:pkg/rand.go","project":"myorg_myproject","securityCategory":"weak-cryptography","vulnerabilityProbability":"MEDIUM","status":"REVIEWED","resolution":"SAFE","line":7,"message":"Make sure that using this pseudorandom number generator is safe here.","author":"herlon214@gmail.com","creationDate":"2021-12-04T15:43:23+0000","updateDate":"2021-12-04T15:43:23+0000","flows":[],"ruleKey":"go:S2245"}],"components":[]}`
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/hotspots/search", r.URL.Path)
+		assert.Equal(t, "3", r.URL.Query().Get("pullRequest"))
+		assert.Equal(t, "myorg_myproject", r.URL.Query().Get("projectKey"))
+
+		w.Write([]byte(expected))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	// Read hotspots
+	hotspots, err := sonar.ListHotspotsForPR("myorg_myproject", "3")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, len(hotspots.Hotspots))
+	assert.Equal(t, "AX2GHjk1-Wk2ioy15Nrx", hotspots.Hotspots[0].Key)
+	assert.Equal(t, "pkg/db.go", hotspots.Hotspots[0].FilePath())
+	assert.Equal(t, "sql-injection", hotspots.Hotspots[0].SecurityCategory)
+	assert.Equal(t, "HIGH", hotspots.Hotspots[0].VulnerabilityProbability)
+	assert.Equal(t, "go:S2077", hotspots.Hotspots[0].RuleKey)
+	assert.Equal(t, 42, hotspots.Hotspots[0].Line)
+
+	toReview := hotspots.FilterByStatus(HOTSPOT_STATUS_TO_REVIEW)
+	assert.Equal(t, 1, len(toReview.Hotspots))
+}
+
+func TestSonarqubeSourceLines(t *testing.T) {
+	// Mock response
+	expected := `{"sources":[{"line":1,"code":"package main","scmRevision":"abc","isNew":false},{"line":2,"code":"func main() {","lineHits":1,"isNew":true},{"line":3,"code":"if ok {","lineHits":1,"conditions":2,"coveredConditions":1,"isNew":true},{"line":4,"code":"run()","lineHits":0,"isNew":true}]}`
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/sources/lines", r.URL.Path)
+		assert.Equal(t, "myproject:main.go", r.URL.Query().Get("key"))
+		assert.Equal(t, "3", r.URL.Query().Get("pullRequest"))
+
+		w.Write([]byte(expected))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	// Read lines
+	lines, err := sonar.SourceLines("myproject:main.go", "3")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 4, len(lines.Sources))
+	assert.Nil(t, lines.Sources[0].LineHits)
+	assert.True(t, lines.Sources[1].IsNew)
+	assert.Equal(t, []int{3, 4}, lines.Uncovered())
+}
+
+func TestSonarqubeDuplications(t *testing.T) {
+	// Mock response
+	expected := `{"duplications":[{"blocks":[{"from":10,"size":5,"_ref":"1"},{"from":30,"size":5,"_ref":"2"}]}],"files":{"1":{"key":"myproject:pkg/a.go","name":"a.go","uuid":"AX1","project":"myproject","projectUuid":"AX0","projectName":"myproject"},"2":{"key":"myproject:pkg/b.go","name":"b.go","uuid":"AX2","project":"myproject","projectUuid":"AX0","projectName":"myproject"}}}`
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/duplications/show", r.URL.Path)
+		assert.Equal(t, "myproject:pkg/a.go", r.URL.Query().Get("key"))
+		assert.Equal(t, "3", r.URL.Query().Get("pullRequest"))
+
+		w.Write([]byte(expected))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	// Read duplications
+	duplications, err := sonar.Duplications("myproject:pkg/a.go", "3")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, len(duplications.Duplications))
+	assert.Equal(t, 2, len(duplications.Duplications[0].Blocks))
+
+	block := duplications.Duplications[0].Blocks[1]
+	assert.Equal(t, 30, block.From)
+	assert.Equal(t, 34, block.To())
+	assert.Equal(t, "pkg/b.go", duplications.File(block).FilePath())
+}
+
+func TestSonarqubePullRequestSummary(t *testing.T) {
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/measures/component", r.URL.Path)
+		assert.Equal(t, "myproject", r.URL.Query().Get("component"))
+
+		if r.URL.Query().Get("pullRequest") == "3" {
+			assert.Equal(t, "new_coverage,new_reliability_rating", r.URL.Query().Get("metricKeys"))
+			w.Write([]byte(`{"component":{"key":"myproject","measures":[{"metric":"new_coverage","period":{"index":1,"value":"50.0"}},{"metric":"new_reliability_rating","period":{"index":1,"value":"1.0"}}]}}`))
+
+			return
+		}
+
+		assert.Equal(t, "main", r.URL.Query().Get("branch"))
+		assert.Equal(t, "coverage,reliability_rating", r.URL.Query().Get("metricKeys"))
+		w.Write([]byte(`{"component":{"key":"myproject","measures":[{"metric":"coverage","value":"75.0"},{"metric":"reliability_rating","value":"3.0"}]}}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	summary, err := sonar.PullRequestSummary("myproject", &PullRequest{Key: "3", Target: "main"}, []string{"new_coverage", "new_reliability_rating"})
+	assert.NoError(t, err)
+	assert.Equal(t, "| Metric | This PR | `main` | Delta |\n|---|---|---|---|\n| Coverage | 50.0% | 75.0% | -25.0% |\n| Reliability | A | C | :arrow_up: better |", summary)
+}
+
+func TestSonarqubeContextCancel(t *testing.T) {
+	done := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer svr.Close()
+	defer close(done)
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithTimeout(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := sonar.ProjectPullRequestsContext(ctx, "myproject")
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestSonarqubeWithTimeout(t *testing.T) {
+	sonar := New("https://my-root", "apiKey", WithTimeout(time.Second))
+
+	assert.Equal(t, time.Second, sonar.httpClient.Timeout)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSonarqubeWithTransport(t *testing.T) {
+	called := false
+	rt := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"pullRequests":[]}`)), Header: http.Header{}}, nil
+	})
+
+	shared := &http.Client{}
+	sonar := New("https://my-root", "apiKey", WithHTTPClient(shared), WithTransport(rt), WithTimeout(time.Second))
+
+	prs, err := sonar.ProjectPullRequests("myproject")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(prs.PullRequests))
+	assert.True(t, called)
+
+	// The shared client is left as it was
+	assert.Nil(t, shared.Transport)
+	assert.Zero(t, shared.Timeout)
+}
+
+// withServerVersion answers the version probe with the given version and passes the other requests to next
+func withServerVersion(version string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/server/version" {
+			w.Write([]byte(version))
+
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func TestSonarqubeVersion(t *testing.T) {
+	probes := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/server/version" {
+			probes++
+			w.Write([]byte("10.2.1.78527"))
+
+			return
+		}
+
+		// Bearer auth and new params since 10.2
+		assert.Equal(t, "Bearer myapikey", r.Header.Get("Authorization"))
+		assert.Equal(t, "myproject", r.URL.Query().Get("components"))
+		w.Write([]byte(`{"issues":[{"key":"AX1","impacts":[{"softwareQuality":"MAINTAINABILITY","severity":"HIGH"}]}]}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	version, err := sonar.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, 10, version.Major)
+	assert.Equal(t, 2, version.Minor)
+	assert.Equal(t, "10.2.1.78527", version.String())
+
+	issues, err := sonar.ListIssuesForPR("myproject", "3")
+	assert.NoError(t, err)
+	assert.Equal(t, "MAINTAINABILITY", issues.Issues[0].Impacts[0].SoftwareQuality)
+
+	// Probed only once
+	assert.Equal(t, 1, probes)
+}
+
+func TestSonarqubeVersionProbeErrors(t *testing.T) {
+	responses := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusServiceUnavailable, ""},
+		{http.StatusNotFound, ""},
+	}
+	probes := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := responses[probes]
+		probes++
+		w.WriteHeader(response.status)
+		w.Write([]byte(response.body))
+	}))
+	defer svr.Close()
+
+	sonar := New(svr.URL, "myapikey")
+
+	// Transient errors are probed again
+	_, err := sonar.Version()
+	assert.Error(t, err)
+	_, err = sonar.Version()
+	assert.Error(t, err)
+	assert.Equal(t, 2, probes)
+
+	// Refused probes are kept
+	_, err = sonar.Version()
+	assert.Error(t, err)
+	assert.Equal(t, 2, probes)
+
+	// Invalid versions are kept with their cause
+	probes = 0
+	responses = []struct {
+		status int
+		body   string
+	}{{http.StatusOK, "not a version"}}
+	sonar = New(svr.URL, "myapikey")
+
+	_, err = sonar.Version()
+	assert.True(t, errors.Is(err, errInvalidVersion))
+	assert.Contains(t, err.Error(), "not a version")
+	_, err = sonar.Version()
+	assert.True(t, errors.Is(err, errInvalidVersion))
+	assert.Equal(t, 1, probes)
+}
+
+func TestSonarqubeOldVersionUsesBasicAuth(t *testing.T) {
+	svr := httptest.NewServer(withServerVersion("8.9.6.50800", func(w http.ResponseWriter, r *http.Request) {
+		user, _, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "myapikey", user)
+		assert.Equal(t, "myproject", r.URL.Query().Get("componentKeys"))
+
+		w.Write([]byte(`{"issues":[]}`))
+	}))
+	defer svr.Close()
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey")
+
+	_, err := sonar.ListIssuesForPR("myproject", "3")
+	assert.NoError(t, err)
+}
+
+func TestSonarqubeWithServerVersion(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEqual(t, "/api/server/version", r.URL.Path)
+		assert.Equal(t, "Bearer myapikey", r.Header.Get("Authorization"))
+
+		w.Write([]byte(`{"pullRequests":[]}`))
+	}))
+	defer svr.Close()
+
+	version, err := ParseVersion("10.0")
+	assert.NoError(t, err)
+
+	// New sonar
+	sonar := New(svr.URL, "myapikey", WithServerVersion(version))
+
+	_, err = sonar.ProjectPullRequests("myproject")
+	assert.NoError(t, err)
+}
+
+func TestSonarqubeValidateToken(t *testing.T) {
+	svr := httptest.NewServer(withServerVersion("9.9.0.65466", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/authentication/validate", r.URL.Path)
+
+		user, _, _ := r.BasicAuth()
+		if user == "myapikey" {
+			w.Write([]byte(`{"valid":true}`))
+
+			return
+		}
+		w.Write([]byte(`{"valid":false}`))
+	}))
+	defer svr.Close()
+
+	valid, err := New(svr.URL, "myapikey").ValidateToken()
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = New(svr.URL, "wrongkey").ValidateToken()
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
@@ -0,0 +1,60 @@
+package sonarqube
+
+type SourceLine struct {
+	Line              int  `json:"line"`
+	LineHits          *int `json:"lineHits"`
+	Conditions        *int `json:"conditions"`
+	CoveredConditions *int `json:"coveredConditions"`
+	IsNew             bool `json:"isNew"`
+}
+
+type SourceLines struct {
+	Sources []SourceLine `json:"sources"`
+}
+
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// IsUncovered checks if the line is coverable and not (or only partially) covered by tests
+func (l SourceLine) IsUncovered() bool {
+	if l.LineHits != nil && *l.LineHits == 0 {
+		return true
+	}
+
+	if l.Conditions != nil && l.CoveredConditions != nil && *l.CoveredConditions < *l.Conditions {
+		return true
+	}
+
+	return false
+}
+
+// Uncovered returns the numbers of the uncovered lines
+func (s SourceLines) Uncovered() []int {
+	lines := make([]int, 0)
+	for _, source := range s.Sources {
+		if source.IsUncovered() {
+			lines = append(lines, source.Line)
+		}
+	}
+
+	return lines
+}
+
+// CollapseLines groups the given sorted line numbers into ranges of consecutive lines
+func CollapseLines(lines []int) []LineRange {
+	ranges := make([]LineRange, 0)
+	for _, line := range lines {
+		last := len(ranges) - 1
+		if last >= 0 && ranges[last].End+1 == line {
+			ranges[last].End = line
+
+			continue
+		}
+
+		ranges = append(ranges, LineRange{Start: line, End: line})
+	}
+
+	return ranges
+}
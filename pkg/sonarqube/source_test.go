@@ -0,0 +1,22 @@
+package sonarqube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceLineIsUncovered(t *testing.T) {
+	zero, one, two := 0, 1, 2
+
+	assert.True(t, SourceLine{LineHits: &zero}.IsUncovered())
+	assert.True(t, SourceLine{LineHits: &one, Conditions: &two, CoveredConditions: &one}.IsUncovered())
+	assert.False(t, SourceLine{LineHits: &one}.IsUncovered())
+	assert.False(t, SourceLine{LineHits: &one, Conditions: &two, CoveredConditions: &two}.IsUncovered())
+	assert.False(t, SourceLine{}.IsUncovered())
+}
+
+func TestCollapseLines(t *testing.T) {
+	assert.Equal(t, []LineRange{{Start: 1, End: 3}, {Start: 5, End: 5}, {Start: 7, End: 8}}, CollapseLines([]int{1, 2, 3, 5, 7, 8}))
+	assert.Equal(t, []LineRange{}, CollapseLines([]int{}))
+}
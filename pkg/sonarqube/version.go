@@ -0,0 +1,65 @@
+package sonarqube
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Raw   string
+}
+
+// ParseVersion parses the version returned by /api/server/version, like 9.9.1.69595
+func ParseVersion(raw string) (*Version, error) {
+	raw = strings.TrimSpace(raw)
+	parts := strings.Split(raw, ".")
+	if len(parts) < 2 {
+		return nil, errors.New(fmt.Sprintf("invalid version %q", raw))
+	}
+
+	numbers := make([]int, 3)
+	for i := 0; i < len(parts) && i < len(numbers); i++ {
+		number, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid version %q", raw))
+		}
+		numbers[i] = number
+	}
+
+	return &Version{
+		Major: numbers[0],
+		Minor: numbers[1],
+		Patch: numbers[2],
+		Raw:   raw,
+	}, nil
+}
+
+// AtLeast checks if the version is the given one or newer
+func (v Version) AtLeast(major int, minor int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+
+	return v.Minor >= minor
+}
+
+// SupportsBearerAuth checks if tokens can be sent as bearer, basic auth with tokens is deprecated since 10.0
+func (v Version) SupportsBearerAuth() bool {
+	return v.AtLeast(10, 0)
+}
+
+// SupportsImpacts checks if the issues have software quality impacts, added in 10.2
+// when the issues search also replaced the componentKeys param by components
+func (v Version) SupportsImpacts() bool {
+	return v.AtLeast(10, 2)
+}
+
+func (v Version) String() string {
+	return v.Raw
+}
@@ -0,0 +1,35 @@
+package sonarqube
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	version, err := ParseVersion("9.9.1.69595\n")
+	assert.NoError(t, err)
+	assert.Equal(t, Version{Major: 9, Minor: 9, Patch: 1, Raw: "9.9.1.69595"}, *version)
+
+	version, err = ParseVersion("10.0")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version.Patch)
+
+	_, err = ParseVersion("{}")
+	assert.Error(t, err)
+	_, err = ParseVersion("ten.one")
+	assert.Error(t, err)
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	version := Version{Major: 9, Minor: 9}
+
+	assert.True(t, version.AtLeast(9, 9))
+	assert.True(t, version.AtLeast(8, 10))
+	assert.False(t, version.AtLeast(9, 10))
+	assert.False(t, version.AtLeast(10, 0))
+	assert.False(t, version.SupportsBearerAuth())
+	assert.True(t, Version{Major: 10, Minor: 0}.SupportsBearerAuth())
+	assert.False(t, Version{Major: 10, Minor: 1}.SupportsImpacts())
+	assert.True(t, Version{Major: 10, Minor: 2}.SupportsImpacts())
+}
@@ -1,12 +1,38 @@
 package sonarqube
 
+import (
+	"net/url"
+)
+
 type WebhookData struct {
-	Status  string `json:"status"`
-	Project struct {
+	ServerURL    string `json:"serverUrl"`
+	Status       string `json:"status"`
+	Revision     string `json:"revision"`
+	Organization string `json:"organization"`
+	Project      struct {
 		Key string `json:"key"`
 	} `json:"project"`
 	Branch struct {
 		Name string `json:"name"`
 		Type string `json:"type"`
+		URL  string `json:"url"`
 	} `json:"branch"`
 }
+
+// BranchName returns the branch name, for PR analyses the PR key is preferably read from the branch url
+func (w WebhookData) BranchName() string {
+	if w.Branch.Type != BRANCH_TYPE_PULL_REQUEST || w.Branch.URL == "" {
+		return w.Branch.Name
+	}
+
+	branchUrl, err := url.Parse(w.Branch.URL)
+	if err != nil {
+		return w.Branch.Name
+	}
+
+	if key := branchUrl.Query().Get("pullRequest"); key != "" {
+		return key
+	}
+
+	return w.Branch.Name
+}
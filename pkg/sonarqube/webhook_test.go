@@ -0,0 +1,19 @@
+package sonarqube
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookBranchName(t *testing.T) {
+	var webhook WebhookData
+	err := json.Unmarshal([]byte(`{"status":"SUCCESS","project":{"key":"myproject"},"branch":{"name":"3","type":"PULL_REQUEST","isMain":false}}`), &webhook)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", webhook.BranchName())
+
+	err = json.Unmarshal([]byte(`{"status":"SUCCESS","project":{"key":"myproject"},"branch":{"name":"feat/test","type":"BRANCH","url":"https://my-sonar/dashboard?id=myproject&branch=feat%2Ftest"}}`), &webhook)
+	assert.NoError(t, err)
+	assert.Equal(t, "feat/test", webhook.BranchName())
+}
@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const githubEnterprisePrefix = "/api/v3"
+
+// ObserveFunc receives the endpoint, status and duration of each request, the status is "error" when no response was received
+type ObserveFunc func(req *http.Request, endpoint string, status string, duration time.Duration)
+
+type instrumented struct {
+	next    http.RoundTripper
+	observe ObserveFunc
+}
+
+// Instrument observes the requests sent through the given round tripper
+func Instrument(next http.RoundTripper, observe ObserveFunc) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &instrumented{next: next, observe: observe}
+}
+
+func (i *instrumented) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := i.next.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(res.StatusCode)
+	}
+	i.observe(req, Endpoint(req.URL.Path), status, time.Since(start))
+
+	return res, err
+}
+
+// Endpoint removes the IDs and repository names from the given path so it can be used as a metric label,
+// like /repos/:owner/:repo/pulls/:id/reviews
+func Endpoint(path string) string {
+	// GitHub Enterprise serves the same API under a prefix
+	path = strings.TrimPrefix(path, githubEnterprisePrefix)
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if _, err := strconv.ParseInt(segment, 10, 64); err == nil {
+			segments[i] = ":id"
+		}
+		if i > 0 && segments[i-1] == "collaborators" {
+			segments[i] = ":user"
+		}
+	}
+
+	// GitHub repository paths
+	if len(segments) > 3 && segments[1] == "repos" {
+		segments[2] = ":owner"
+		segments[3] = ":repo"
+	}
+
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer svr.Close()
+
+	var observed []string
+	client := &http.Client{Transport: Instrument(nil, func(req *http.Request, endpoint string, status string, duration time.Duration) {
+		observed = append(observed, endpoint+" "+status)
+	})}
+
+	res, err := client.Get(svr.URL + "/api/issues/search?pullRequest=3")
+	assert.NoError(t, err)
+	res.Body.Close()
+
+	_, err = client.Get("http://127.0.0.1:0/api/server/version")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{"/api/issues/search 404", "/api/server/version error"}, observed)
+}
+
+func TestEndpoint(t *testing.T) {
+	assert.Equal(t, "/api/issues/search", Endpoint("/api/issues/search"))
+	assert.Equal(t, "/repos/:owner/:repo/pulls/:id/reviews", Endpoint("/repos/herlon214/sonarqube-pr-issues/pulls/3/reviews"))
+	assert.Equal(t, "/repos/:owner/:repo/pulls/comments/:id/reactions", Endpoint("/repos/herlon214/sonarqube-pr-issues/pulls/comments/10/reactions"))
+	assert.Equal(t, "/repos/:owner/:repo/collaborators/:user/permission", Endpoint("/repos/herlon214/sonarqube-pr-issues/collaborators/octocat/permission"))
+	assert.Equal(t, "/repos/:owner/:repo/pulls/:id/reviews", Endpoint("/api/v3/repos/herlon214/sonarqube-pr-issues/pulls/3/reviews"))
+	assert.Equal(t, "/rate_limit", Endpoint("/rate_limit"))
+}
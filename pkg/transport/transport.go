@@ -0,0 +1,69 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	// CABundles are PEM files with extra certificate authorities to trust
+	CABundles []string
+	// ClientCert and ClientKey are the PEM files of the certificate used for mutual TLS
+	ClientCert string
+	ClientKey  string
+}
+
+// New creates an HTTP transport with the given TLS configuration,
+// proxies are read from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func New(config Config) (*http.Transport, error) {
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+	httpTransport.Proxy = http.ProxyFromEnvironment
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	// Extra certificate authorities
+	if len(config.CABundles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, bundle := range config.CABundles {
+			pem, err := os.ReadFile(bundle)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("failed to read CA bundle %s", bundle))
+			}
+
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.New(fmt.Sprintf("no certificates found in CA bundle %s", bundle))
+			}
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	// Client certificate
+	if config.ClientCert != "" || config.ClientKey != "" {
+		if config.ClientCert == "" || config.ClientKey == "" {
+			return nil, errors.New("both client certificate and key are required")
+		}
+
+		cert, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	httpTransport.TLSClientConfig = tlsConfig
+
+	return httpTransport, nil
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate creates a self signed certificate and writes it with its key into the given dir
+func writeCertificate(t *testing.T, dir string, name string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	assert.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certPath, keyPath
+}
+
+// writeServerCA writes the certificate of the given TLS server into the given dir
+func writeServerCA(t *testing.T, dir string, svr *httptest.Server) string {
+	caPath := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: svr.Certificate().Raw}), 0600))
+
+	return caPath
+}
+
+func TestNewDefault(t *testing.T) {
+	httpTransport, err := New(Config{})
+	assert.NoError(t, err)
+
+	assert.NotNil(t, httpTransport.Proxy)
+	assert.Nil(t, httpTransport.TLSClientConfig.RootCAs)
+}
+
+func TestNewWithCABundle(t *testing.T) {
+	svr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer svr.Close()
+
+	// Unknown authority
+	httpTransport, err := New(Config{})
+	assert.NoError(t, err)
+	_, err = (&http.Client{Transport: httpTransport}).Get(svr.URL)
+	assert.Error(t, err)
+
+	// Trusted authority
+	httpTransport, err = New(Config{CABundles: []string{writeServerCA(t, t.TempDir(), svr)}})
+	assert.NoError(t, err)
+	res, err := (&http.Client{Transport: httpTransport}).Get(svr.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestNewWithClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeCertificate(t, dir, "client")
+
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "client", r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	svr.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	svr.StartTLS()
+	defer svr.Close()
+
+	httpTransport, err := New(Config{CABundles: []string{writeServerCA(t, dir, svr)}, ClientCert: certPath, ClientKey: keyPath})
+	assert.NoError(t, err)
+
+	res, err := (&http.Client{Transport: httpTransport}).Get(svr.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	_, err := New(Config{CABundles: []string{"missing.pem"}})
+	assert.Error(t, err)
+
+	_, err = New(Config{ClientCert: "client.crt"})
+	assert.Equal(t, "both client certificate and key are required", err.Error())
+}